@@ -17,6 +17,7 @@
 package cl
 
 import (
+	"errors"
 	"go/types"
 	"testing"
 
@@ -25,17 +26,6 @@ import (
 	"github.com/goplus/gox"
 )
 
-func TestCompileErrWrapExpr(t *testing.T) {
-	defer func() {
-		if e := recover(); e != "TODO: can't use expr? in global" {
-			t.Fatal("TestCompileErrWrapExpr failed")
-		}
-	}()
-	pkg := gox.NewPackage("", "foo", nil)
-	ctx := &blockCtx{pkg: pkg, cb: pkg.CB()}
-	compileErrWrapExpr(ctx, &ast.ErrWrapExpr{Tok: token.QUESTION})
-}
-
 func TestToString(t *testing.T) {
 	defer func() {
 		if e := recover(); e == nil {
@@ -65,16 +55,16 @@ func TestIsFunc(t *testing.T) {
 
 func TestHandleRecover(t *testing.T) {
 	var ctx pkgCtx
-	ctx.handleRecover("hello")
+	ctx.handleRecover(errors.New("hello"))
 	if !(len(ctx.errs) == 1 && ctx.errs[0].Error() == "hello") {
 		t.Fatal("TestHandleRecover failed:", ctx.errs)
 	}
 	defer func() {
-		if e := recover(); e == nil {
-			t.Fatal("TestHandleRecover failed: no error?")
+		if e := recover(); e != "TODO: hello" {
+			t.Fatal("TestHandleRecover failed:", e)
 		}
 	}()
-	ctx.handleRecover(100)
+	ctx.handleRecover("TODO: hello")
 }
 
 func TestCanAutoCall(t *testing.T) {
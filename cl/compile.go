@@ -26,9 +26,11 @@ import (
 	"os"
 	"path"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/parser"
 	"github.com/goplus/gop/token"
 	"github.com/goplus/gox"
 )
@@ -141,6 +143,33 @@ type Config struct {
 
 	// RelativePath = true means to generate file line comments with relative file path.
 	RelativePath bool
+
+	// NewBuiltin creates the builtin package that Go+ code can access without
+	// importing anything. If NewBuiltin is nil, the default Go+ builtins are used.
+	// It can be used to restrict what a script is able to call. Operators need
+	// the functions that gox.InitBuiltin defines, and the compiler itself calls
+	// append for list comprehensions, panic for expr! and new in the main func
+	// of a .gmx file.
+	NewBuiltin func(pkg gox.PkgImporter, conf *gox.Config) *types.Package
+
+	// CompiledPkgs maps import paths to Go+ packages compiled before. Imports
+	// of these paths use the compiled packages instead of loading them, and
+	// imports of the packages they import use the same packages they do.
+	CompiledPkgs map[string]*gox.Package
+
+	// CheckUnusedImports = true means to report imported packages that are not
+	// used, as Go does.
+	CheckUnusedImports bool
+
+	// CheckUnusedVars = true means to report local variables that are declared
+	// but never used, as Go does.
+	CheckUnusedVars bool
+
+	// MaxErrors limits how many errors are reported when compiling a package.
+	// If there are more errors, only the first MaxErrors ones in the order of
+	// their positions are reported, whatever order the files are compiled in.
+	// If MaxErrors is zero, all errors are reported.
+	MaxErrors int
 }
 
 func (conf *Config) Ensure() *Config {
@@ -180,6 +209,10 @@ func (p *nodeInterp) Caller(node ast.Node) string {
 func (p *nodeInterp) LoadExpr(node ast.Node) (src string, pos token.Position) {
 	start := node.Pos()
 	pos = p.fset.Position(start)
+	if _, ok := node.(*ast.FuncLit); ok {
+		pos.Filename = relFile(p.workingDir, pos.Filename)
+		return "func literal", pos
+	}
 	f := p.files[pos.Filename]
 	n := int(node.End() - start)
 	pos.Filename = relFile(p.workingDir, pos.Filename)
@@ -206,6 +239,15 @@ func initLoader(ctx *pkgCtx, syms map[string]loader, start token.Pos, name strin
 		return
 	}
 	if old, ok := syms[name]; ok {
+		if ld, ok := old.(*typeLoader); ok && ld.start == token.NoPos {
+			// only methods are declared on name
+			for _, d := range ld.mdecls {
+				pos := ctx.Position(recvType(d.Recv).Pos())
+				ctx.handleCodeErrorf(&pos, "%s is not a type", name)
+			}
+			syms[name] = &baseLoader{start: start, fn: fn}
+			return
+		}
 		var pos token.Position
 		if start != token.NoPos {
 			pos = ctx.Position(start)
@@ -229,20 +271,33 @@ func (p *baseLoader) pos() token.Pos {
 type typeLoader struct {
 	typ, typInit func()
 	methods      []func()
+	mdecls       []*ast.FuncDecl // methods declared on this type
 	start        token.Pos
 }
 
-func getTypeLoader(syms map[string]loader, start token.Pos, name string) *typeLoader {
+// getTypeLoader returns the loader of the type name. It returns nil if start
+// is NoPos and name is declared but not as a type.
+func getTypeLoader(ctx *pkgCtx, syms map[string]loader, start token.Pos, name string) *typeLoader {
 	t, ok := syms[name]
 	if ok {
 		if start != token.NoPos {
-			panic("TODO: redefine")
+			ld, ok := t.(*typeLoader)
+			if !ok || ld.start != token.NoPos {
+				pos := ctx.Position(start)
+				oldpos := ctx.Position(t.pos())
+				ctx.handleCodeErrorf(
+					&pos, "%s redeclared in this block\n\tprevious declaration at %v", name, oldpos)
+				return &typeLoader{start: start}
+			}
+			ld.start = start // created by a method declared before this type
+			return ld
 		}
 	} else {
 		t = &typeLoader{start: start}
 		syms[name] = t
 	}
-	return t.(*typeLoader)
+	ld, _ := t.(*typeLoader)
+	return ld
 }
 
 func (p *typeLoader) pos() token.Pos {
@@ -281,10 +336,22 @@ func doInitMethods(ld *typeLoader) {
 type pkgCtx struct {
 	*nodeInterp
 	*gmxSettings
-	syms  map[string]loader
-	inits []func()
-	tylds []*typeLoader
-	errs  []error
+	syms     map[string]loader
+	inits    []func()
+	tylds    []*typeLoader
+	tychks   []func() // checks to run when all types are loaded
+	errs     []error
+	fileCtxs []*blockCtx // files to check for unused imports
+	loadPkgs gox.LoadPkgsFunc
+	specs    map[string]*ast.ImportSpec // import declarations by package path
+	imported []*gox.PkgRef
+	pkgNames []func() // to name imports by their package names when loaded
+
+	used       map[types.Object]bool       // local variables used, if checked
+	funcScopes map[*types.Scope]bool       // scopes of function bodies, if checked
+	varIdents  map[types.Object]*ast.Ident // range and type switch variables, if checked
+
+	maxErrs int
 }
 
 type blockCtx struct {
@@ -293,11 +360,16 @@ type blockCtx struct {
 	cb           *gox.CodeBuilder
 	fset         *token.FileSet
 	imports      map[string]*gox.PkgRef
+	unused       map[string]*ast.ImportSpec      // imports not used yet, if checked
+	unusedDots   map[*gox.PkgRef]*ast.ImportSpec // dot imports not used yet, if checked
 	lookups      []*gox.PkgRef
+	labels       []*ast.LabeledStmt // enclosing labeled statements
+	blocks       []*stmtBlock       // enclosing statement lists
 	targetDir    string
 	classRecv    *ast.FieldList // avaliable when gmxSettings != nil
 	fileLine     bool
 	relativePath bool
+	inConst      bool // compiling values of a constant declaration
 	fileType     int16
 }
 
@@ -323,6 +395,34 @@ func (p *pkgCtx) handleErr(err error) {
 	p.errs = append(p.errs, err)
 }
 
+// loadImports loads the packages of pkgPaths in one batch. If the batch can't
+// be loaded, they are loaded one by one to report the unknown ones at their
+// import declarations.
+func (p *pkgCtx) loadImports(at *gox.Package, importPkgs map[string]*gox.PkgRef, pkgPaths ...string) int {
+	n := p.loadPkgs(at, importPkgs, pkgPaths...)
+	if n == 0 {
+		return 0
+	}
+	n = 0
+	for _, pkgPath := range pkgPaths {
+		if len(pkgPaths) > 1 && p.loadPkgs(at, importPkgs, pkgPath) == 0 {
+			continue
+		}
+		spec, ok := p.specs[pkgPath]
+		if !ok {
+			n++
+			continue
+		}
+		pos := p.Position(spec.Path.Pos())
+		p.handleCodeErrorf(&pos, "could not import %s", pkgPath)
+		if ref, ok := importPkgs[pkgPath]; ok { // an empty package
+			ref.Types = types.NewPackage(pkgPath, path.Base(pkgPath))
+			ref.Types.MarkComplete()
+		}
+	}
+	return n
+}
+
 func (p *pkgCtx) loadNamed(at *gox.Package, t *types.Named) {
 	o := t.Obj()
 	if o.Pkg() == at.Types {
@@ -331,12 +431,90 @@ func (p *pkgCtx) loadNamed(at *gox.Package, t *types.Named) {
 }
 
 func (p *pkgCtx) complete() error {
-	if p.errs != nil {
-		return &Errors{Errs: p.errs}
+	if errs := p.errs; errs != nil {
+		if p.maxErrs > 0 && len(errs) > p.maxErrs {
+			sort.SliceStable(errs, func(i, j int) bool {
+				return errPosLess(errs[i], errs[j])
+			})
+			errs = errs[:p.maxErrs]
+		}
+		return &Errors{Errs: errs}
 	}
 	return nil
 }
 
+// errPosLess reports whether the error a is at a position before b. Errors
+// without positions are after all others.
+func errPosLess(a, b error) bool {
+	var pa, pb *token.Position
+	if e, ok := a.(*gox.CodeError); ok {
+		pa = e.Pos
+	}
+	if e, ok := b.(*gox.CodeError); ok {
+		pb = e.Pos
+	}
+	if pa == nil || pb == nil {
+		return pa != nil
+	}
+	if pa.Filename != pb.Filename {
+		return pa.Filename < pb.Filename
+	}
+	if pa.Line != pb.Line {
+		return pa.Line < pb.Line
+	}
+	return pa.Column < pb.Column
+}
+
+// checkRecvTypes reports methods declared on types that are not declared in
+// this package, and drops them so that they are not loaded.
+func (p *pkgCtx) checkRecvTypes() {
+	var names []string
+	for name, sym := range p.syms {
+		if types.Universe.Lookup(name) != nil {
+			continue // reported when the methods are loaded
+		}
+		if ld, ok := sym.(*typeLoader); ok && ld.start == token.NoPos && ld.typ == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ld := p.syms[name].(*typeLoader)
+		delete(p.syms, name)
+		methods := make([]string, len(ld.mdecls))
+		for i, d := range ld.mdecls {
+			methods[i] = d.Name.Name
+		}
+		pos := p.Position(recvType(ld.mdecls[0].Recv).Pos())
+		p.handleCodeErrorf(&pos, "undefined: %s (receiver type of %s)", name, strings.Join(methods, ", "))
+	}
+}
+
+// checkUnusedImports reports imported packages that are not referenced by the
+// files importing them. It must be called after all symbols are loaded.
+func (p *pkgCtx) checkUnusedImports() {
+	var specs []*ast.ImportSpec
+	for _, f := range p.fileCtxs {
+		for _, spec := range f.unused {
+			specs = append(specs, spec)
+		}
+		for _, spec := range f.unusedDots {
+			specs = append(specs, spec)
+		}
+	}
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].Pos() < specs[j].Pos()
+	})
+	for _, spec := range specs {
+		pos := p.Position(spec.Pos())
+		if spec.Name != nil && spec.Name.Name != "." {
+			p.handleCodeErrorf(&pos, "%s imported but not used as %s", spec.Path.Value, spec.Name.Name)
+		} else {
+			p.handleCodeErrorf(&pos, "%s imported but not used", spec.Path.Value)
+		}
+	}
+}
+
 func (p *pkgCtx) loadType(name string) {
 	if sym, ok := p.syms[name]; ok {
 		if ld, ok := sym.(*typeLoader); ok {
@@ -365,14 +543,12 @@ func (p *pkgCtx) loadSymbol(name string) bool {
 	return false
 }
 
+// handleRecover reports a recovered compile error. Anything else, such as an
+// internal "TODO" panic, is not a user error and keeps panicking.
 func (p *pkgCtx) handleRecover(e interface{}) {
 	err, ok := e.(error)
 	if !ok {
-		if msg, ok := e.(string); ok {
-			err = errors.New(msg)
-		} else {
-			panic(e)
-		}
+		panic(e)
 	}
 	p.handleErr(err)
 }
@@ -392,8 +568,24 @@ func NewPackage(pkgPath string, pkg *ast.Package, conf *Config) (p *gox.Package,
 	if targetDir == "" {
 		targetDir = dir
 	}
+	newBuiltin := conf.NewBuiltin
+	if newBuiltin == nil {
+		newBuiltin = newBuiltinDefault
+	}
 	interp := &nodeInterp{fset: conf.Fset, files: pkg.Files, workingDir: workingDir}
-	ctx := &pkgCtx{syms: make(map[string]loader), nodeInterp: interp}
+	ctx := &pkgCtx{
+		syms: make(map[string]loader), specs: make(map[string]*ast.ImportSpec),
+		nodeInterp: interp, maxErrs: conf.MaxErrors,
+	}
+	ctx.loadPkgs = conf.PkgsLoader.LoadPkgs
+	if conf.CompiledPkgs != nil {
+		ctx.loadPkgs = loadCompiledPkgs(conf.CompiledPkgs, ctx.loadPkgs)
+	}
+	if conf.CheckUnusedVars {
+		ctx.used = make(map[types.Object]bool)
+		ctx.funcScopes = make(map[*types.Scope]bool)
+		ctx.varIdents = make(map[types.Object]*ast.Ident)
+	}
 	confGox := &gox.Config{
 		Context:         conf.Context,
 		Logf:            conf.Logf,
@@ -402,12 +594,12 @@ func NewPackage(pkgPath string, pkg *ast.Package, conf *Config) (p *gox.Package,
 		Env:             conf.Env,
 		BuildFlags:      conf.BuildFlags,
 		Fset:            conf.Fset,
-		LoadPkgs:        conf.PkgsLoader.LoadPkgs,
+		LoadPkgs:        ctx.loadImports,
 		LoadNamed:       ctx.loadNamed,
 		HandleErr:       ctx.handleErr,
 		NodeInterpreter: interp,
 		ParseFile:       nil, // TODO
-		NewBuiltin:      newBuiltinDefault,
+		NewBuiltin:      newBuiltin,
 	}
 	p = gox.NewPackage(pkgPath, pkg.Name, confGox)
 	for file, gmx := range pkg.Files {
@@ -419,6 +611,16 @@ func NewPackage(pkgPath string, pkg *ast.Package, conf *Config) (p *gox.Package,
 	for fpath, f := range pkg.Files {
 		preloadFile(p, ctx, fpath, f, targetDir, conf)
 	}
+	imports := make([]*types.Package, len(ctx.imported))
+	for i, pkg := range ctx.imported {
+		pkg.EnsureImported() // imports are loaded in one batch
+		imports[i] = pkg.Types
+	}
+	p.Types.SetImports(imports) // see loadCompiledPkgs
+	for _, name := range ctx.pkgNames {
+		name()
+	}
+	ctx.checkRecvTypes()
 	for _, f := range pkg.Files {
 		if f.FileType == ast.FileTypeGmx {
 			loadFile(ctx, f)
@@ -434,13 +636,51 @@ func NewPackage(pkgPath string, pkg *ast.Package, conf *Config) (p *gox.Package,
 	for _, ld := range ctx.tylds {
 		ld.load()
 	}
+	for _, check := range ctx.tychks {
+		check()
+	}
 	for _, load := range ctx.inits {
 		load()
 	}
+	ctx.checkUnusedImports()
 	err = ctx.complete()
 	return
 }
 
+// CompileSource parses Go+ source files and compiles them as one package. The
+// keys of files are file names and the values are their source code. Parse
+// errors are returned with their positions.
+func CompileSource(pkgPath string, files map[string]string, conf *Config) (p *gox.Package, err error) {
+	conf = conf.Ensure()
+	if conf.Fset == nil { // don't change the caller's conf
+		c := *conf
+		c.Fset = token.NewFileSet()
+		conf = &c
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var pkg *ast.Package
+	for _, name := range names {
+		f, err := parser.ParseFile(conf.Fset, name, files[name], 0)
+		if err != nil {
+			return nil, err
+		}
+		if pkg == nil {
+			pkg = &ast.Package{Name: f.Name.Name, Files: make(map[string]*ast.File)}
+		} else if f.Name.Name != pkg.Name {
+			return nil, fmt.Errorf("%s: found package %s, expected %s", name, f.Name.Name, pkg.Name)
+		}
+		pkg.Files[name] = f
+	}
+	if pkg == nil {
+		return nil, errors.New("no Go+ source files")
+	}
+	return NewPackage(pkgPath, pkg, conf)
+}
+
 func hasMethod(o types.Object, name string) bool {
 	if obj, ok := o.(*types.TypeName); ok {
 		if t, ok := obj.Type().(*types.Named); ok {
@@ -463,9 +703,11 @@ func loadFile(ctx *pkgCtx, f *ast.File) {
 				if name != "init" {
 					ctx.loadSymbol(name)
 				}
-			} else {
+			} else if d.Recv.NumFields() == 1 {
 				if name, ok := getRecvTypeName(ctx, d.Recv, false); ok {
-					getTypeLoader(ctx.syms, token.NoPos, name).load()
+					if ld := getTypeLoader(ctx, ctx.syms, token.NoPos, name); ld != nil {
+						ld.load()
+					}
 				}
 			}
 		case *ast.GenDecl:
@@ -493,6 +735,11 @@ func preloadFile(p *gox.Package, parent *pkgCtx, file string, f *ast.File, targe
 		pkg: p, pkgCtx: parent, cb: p.CB(), fset: p.Fset, targetDir: targetDir, fileType: f.FileType,
 		fileLine: fileLine, relativePath: conf.RelativePath, imports: make(map[string]*gox.PkgRef),
 	}
+	if conf.CheckUnusedImports {
+		ctx.unused = make(map[string]*ast.ImportSpec)
+		ctx.unusedDots = make(map[*gox.PkgRef]*ast.ImportSpec)
+		parent.fileCtxs = append(parent.fileCtxs, ctx)
+	}
 	var classType string
 	var baseTypeName string
 	var baseType types.Type
@@ -522,7 +769,7 @@ func preloadFile(p *gox.Package, parent *pkgCtx, file string, f *ast.File, targe
 		}
 		pos := f.Pos()
 		specs := getFields(ctx, f)
-		ld := getTypeLoader(syms, pos, classType)
+		ld := getTypeLoader(parent, syms, pos, classType)
 		ld.typ = func() {
 			if debugLoad {
 				log.Println("==> Load > NewType", classType)
@@ -587,11 +834,26 @@ func preloadFile(p *gox.Package, parent *pkgCtx, file string, f *ast.File, targe
 					initLoader(parent, syms, name.Pos(), name.Name, fn)
 				}
 			} else {
+				if !checkRecv(parent, d.Recv) {
+					continue
+				}
 				if name, ok := getRecvTypeName(parent, d.Recv, true); ok {
 					if debugLoad {
 						log.Printf("==> Preload method %s.%s\n", name, d.Name.Name)
 					}
-					ld := getTypeLoader(syms, token.NoPos, name)
+					ld := getTypeLoader(parent, syms, token.NoPos, name)
+					if ld == nil {
+						pos := parent.Position(recvType(d.Recv).Pos())
+						parent.handleCodeErrorf(&pos, "%s is not a type", name)
+						continue
+					}
+					if old := findMethodDecl(ld, d.Name.Name); old != nil {
+						pos := parent.Position(d.Name.Pos())
+						parent.handleCodeErrorf(&pos, "method %s.%s already declared at %v",
+							name, d.Name.Name, parent.Position(old.Name.Pos()))
+						continue
+					}
+					ld.mdecls = append(ld.mdecls, d)
 					ld.methods = append(ld.methods, func() {
 						old := p.SetInTestingFile(testingFile)
 						defer p.SetInTestingFile(old)
@@ -615,7 +877,7 @@ func preloadFile(p *gox.Package, parent *pkgCtx, file string, f *ast.File, targe
 					if debugLoad {
 						log.Println("==> Preload type", name)
 					}
-					ld := getTypeLoader(syms, t.Name.Pos(), name)
+					ld := getTypeLoader(parent, syms, t.Name.Pos(), name)
 					ld.typ = func() {
 						old := p.SetInTestingFile(testingFile)
 						defer p.SetInTestingFile(old)
@@ -635,6 +897,10 @@ func preloadFile(p *gox.Package, parent *pkgCtx, file string, f *ast.File, targe
 								log.Println("==> Load > InitType", name)
 							}
 							decl.InitType(ctx.pkg, toType(ctx, t.Type))
+							if named := decl.Type(); embedsType(named, named.Underlying(), nil) {
+								pos := ctx.Position(t.Name.Pos())
+								ctx.handleCodeErrorf(&pos, "invalid recursive type %s", name)
+							}
 						}
 					}
 				}
@@ -676,10 +942,12 @@ func preloadFile(p *gox.Package, parent *pkgCtx, file string, f *ast.File, targe
 					})
 				}
 			default:
-				log.Panicln("TODO - tok:", d.Tok, "spec:", reflect.TypeOf(d.Specs).Elem())
+				pos := parent.Position(d.Pos())
+				parent.handleCodeErrorf(&pos, "unknown %v declaration", d.Tok)
 			}
 		default:
-			log.Panicln("TODO - gopkg.Package.load: unknown decl -", reflect.TypeOf(decl))
+			pos := parent.Position(decl.Pos())
+			parent.handleCodeErrorf(&pos, "unknown declaration %v", reflect.TypeOf(decl))
 		}
 	}
 }
@@ -780,29 +1048,108 @@ var unaryGopNames = map[string]string{
 }
 
 func loadFuncBody(ctx *blockCtx, fn *gox.Func, body *ast.BlockStmt) {
+	labels, blocks := ctx.labels, ctx.blocks
+	ctx.labels, ctx.blocks = nil, nil
 	cb := fn.BodyStart(ctx.pkg)
 	compileStmts(ctx, body.List)
+	if ctx.used != nil {
+		ctx.checkUnusedVars(cb.Scope(), fn.Type().(*types.Signature))
+	}
 	cb.End()
+	ctx.labels, ctx.blocks = labels, blocks
+}
+
+// checkUnusedVars reports local variables of a function body that are never
+// used. Parameters and results are not reported, and closures are checked
+// when their own bodies are loaded.
+func (p *pkgCtx) checkUnusedVars(scope *types.Scope, sig *types.Signature) {
+	p.funcScopes[scope] = true
+	params := map[types.Object]bool{sig.Recv(): true}
+	for _, t := range []*types.Tuple{sig.Params(), sig.Results()} {
+		for i, n := 0, t.Len(); i < n; i++ {
+			params[t.At(i)] = true
+		}
+	}
+	// a type switch variable is declared in every clause, and is used if it
+	// is used in any of them
+	usedIdents := make(map[*ast.Ident]bool)
+	var unused []*ast.Ident
+	var walk func(scope *types.Scope)
+	walk = func(scope *types.Scope) {
+		for _, name := range scope.Names() {
+			o := scope.Lookup(name)
+			if _, ok := o.(*types.Var); !ok || params[o] {
+				continue
+			}
+			ident, ok := p.varIdents[o]
+			if !ok {
+				if o.Pos() == token.NoPos {
+					continue
+				}
+				ident = &ast.Ident{NamePos: o.Pos(), Name: o.Name()}
+			}
+			if p.used[o] {
+				usedIdents[ident] = true
+			} else {
+				unused = append(unused, ident)
+			}
+		}
+		for i, n := 0, scope.NumChildren(); i < n; i++ {
+			if child := scope.Child(i); !p.funcScopes[child] {
+				walk(child)
+			}
+		}
+	}
+	walk(scope)
+	sort.Slice(unused, func(i, j int) bool {
+		return unused[i].Pos() < unused[j].Pos()
+	})
+	for _, ident := range unused {
+		if usedIdents[ident] {
+			continue
+		}
+		usedIdents[ident] = true // reported
+		pos := p.Position(ident.Pos())
+		p.handleCodeErrorf(&pos, "%s declared but not used", ident.Name)
+	}
 }
 
 func loadImport(ctx *blockCtx, spec *ast.ImportSpec) {
 	pkgPath := toString(spec.Path)
+	if pkgPath == ctx.pkg.Types.Path() {
+		pos := ctx.Position(spec.Path.Pos())
+		ctx.handleCodeErrorf(&pos, "import cycle not allowed: %s imports itself", pkgPath)
+		return
+	}
 	pkg := ctx.pkg.Import(pkgPath)
-	var name string
-	if spec.Name != nil {
-		name = spec.Name.Name
-		if name == "." {
-			ctx.lookups = append(ctx.lookups, pkg)
-			return
-		}
-		if name == "_" {
-			pkg.MarkForceUsed()
-			return
+	if _, ok := ctx.specs[pkgPath]; !ok {
+		ctx.specs[pkgPath] = spec
+		ctx.imported = append(ctx.imported, pkg)
+	}
+	if spec.Name == nil { // the package name is known when it is loaded
+		ctx.pkgNames = append(ctx.pkgNames, func() {
+			ctx.importAs(pkg.Types.Name(), pkg, spec)
+		})
+		return
+	}
+	switch name := spec.Name.Name; name {
+	case ".":
+		ctx.lookups = append(ctx.lookups, pkg)
+		if ctx.unusedDots != nil {
+			ctx.unusedDots[pkg] = spec
 		}
-	} else {
-		name = path.Base(pkgPath) // TODO: open pkgPath to get pkgName
+	case "_":
+		pkg.MarkForceUsed()
+	default:
+		ctx.importAs(name, pkg, spec)
 	}
+}
+
+func (ctx *blockCtx) importAs(name string, pkg *gox.PkgRef, spec *ast.ImportSpec) {
 	ctx.imports[name] = pkg
+	if ctx.unused != nil {
+		ctx.unused[name] = spec
+	}
 }
 
 func loadConstSpecs(ctx *blockCtx, cdecl *gox.ConstDecl, specs []ast.Spec) {
@@ -828,13 +1175,31 @@ func loadConsts(ctx *blockCtx, cdecl *gox.ConstDecl, v *ast.ValueSpec, iotav int
 	if debugLoad {
 		log.Println("==> Load const", names, typ)
 	}
+	initing := true
 	fn := func(cb *gox.CodeBuilder) int {
+		if initing && enableRecover { // Next doesn't start an init expression
+			defer func() {
+				if e := recover(); e != nil {
+					cb.ResetInit()
+					panic(e)
+				}
+			}()
+		}
+		inConst := ctx.inConst
+		ctx.inConst = true
+		defer func() {
+			ctx.inConst = inConst
+		}()
 		for _, val := range v.Values {
 			compileExpr(ctx, val)
 		}
+		if typ != nil {
+			checkIntOverflow(ctx, typ, v.Values)
+		}
 		return len(v.Values)
 	}
 	cdecl.New(fn, iotav, v.Pos(), typ, names...)
+	initing = false
 }
 
 func loadVars(ctx *blockCtx, v *ast.ValueSpec, global bool) {
@@ -855,12 +1220,25 @@ func loadVars(ctx *blockCtx, v *ast.ValueSpec, global bool) {
 	varDecl := ctx.pkg.NewVarEx(scope, v.Names[0].Pos(), typ, names...)
 	if nv := len(v.Values); nv > 0 {
 		cb := varDecl.InitStart(ctx.pkg)
+		if enableRecover {
+			defer func() {
+				if e := recover(); e != nil {
+					cb.ResetInit()
+					panic(e)
+				}
+			}()
+		}
 		if nv == 1 && len(names) == 2 {
 			compileExpr(ctx, v.Values[0], true)
 		} else {
 			for _, val := range v.Values {
 				compileExpr(ctx, val)
 			}
+			if typ != nil {
+				checkIntOverflow(ctx, typ, v.Values)
+			} else {
+				checkUntypedNil(ctx, v.Values, "variable declaration")
+			}
 		}
 		cb.EndInit(nv)
 	}
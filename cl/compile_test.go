@@ -18,7 +18,9 @@ package cl_test
 
 import (
 	"bytes"
+	"go/types"
 	"os"
+	"reflect"
 	"sync"
 	"syscall"
 	"testing"
@@ -91,6 +93,28 @@ func gopClTest(t *testing.T, gopcode, expected string, cachefile ...string) {
 	}
 }
 
+// gopClTestFiles compiles files as the package pkgPath and compares the code
+// generated with expected. Files are compiled in no particular order, so the
+// code may match any one of expected.
+func gopClTestFiles(t *testing.T, conf *cl.Config, pkgPath string, files map[string]string, expected ...string) {
+	pkg, err := cl.CompileSource(pkgPath, files, conf)
+	if err != nil {
+		t.Fatal("CompileSource:", err)
+	}
+	var b bytes.Buffer
+	err = gox.WriteTo(&b, pkg, false)
+	if err != nil {
+		t.Fatal("gox.WriteTo failed:", err)
+	}
+	result := b.String()
+	for _, v := range expected {
+		if result == v {
+			return
+		}
+	}
+	t.Fatalf("\nResult:\n%s\nExpected:\n%s\n", result, expected[0])
+}
+
 func TestEmptyPkgsLoader(t *testing.T) {
 	l := &cl.PkgsLoader{}
 	if l.Save() != nil {
@@ -124,6 +148,29 @@ func init() {
 }
 func init() {
 }
+`)
+	gopClTest(t, `
+var a = 1
+
+func init() {
+	a = 2
+}
+
+func init() {
+	println a
+}
+`, `package main
+
+import fmt "fmt"
+
+var a = 1
+
+func init() {
+	a = 2
+}
+func init() {
+	fmt.Println(a)
+}
 `)
 }
 
@@ -679,6 +726,43 @@ func main() {
 `)
 }
 
+func TestShadowInNestedBlocks(t *testing.T) {
+	gopClTest(t, `
+x := 1
+if x > 0 {
+	x := "s"
+	println(x)
+}
+for i := 0; i < 2; i++ {
+	x := i * 2
+	if x > 0 {
+		x := float64(x)
+		println(x)
+	}
+}
+println(x)
+`, `package main
+
+import fmt "fmt"
+
+func main() {
+	x := 1
+	if x > 0 {
+		x := "s"
+		fmt.Println(x)
+	}
+	for i := 0; i < 2; i++ {
+		x := i * 2
+		if x > 0 {
+			x := float64(x)
+			fmt.Println(x)
+		}
+	}
+	fmt.Println(x)
+}
+`)
+}
+
 func TestConstTypeConvIssue792(t *testing.T) {
 	gopClTest(t, `
 const dots = ". . . " + ". . . . . "
@@ -767,6 +851,56 @@ var i = 100
 `)
 }
 
+func TestConstFolding(t *testing.T) {
+	gopClTest(t, `
+const KB = 1 << 10
+
+var a [KB / 256]int
+
+const f = 1.5 * 2
+const s = "a" + "b"
+const b = KB > 512
+const c uint64 = 1<<64 - 1
+`, `package main
+
+const KB = 1 << 10
+
+var a [4]int
+
+const f = 1.5 * 2
+const s = "a" + "b"
+const b = true
+const c uint64 = 1<<64 - 1
+`)
+}
+
+func TestConstAfterMain(t *testing.T) {
+	gopClTest(t, `
+package main
+
+func main() {
+	println(c, d)
+}
+
+const (
+	c    = 100
+	d, e = "Hi", 1.5
+)
+`, `package main
+
+import fmt "fmt"
+
+func main() {
+	fmt.Println(c, d)
+}
+
+const (
+	c    = 100
+	d, e = "Hi", 1.5
+)
+`)
+}
+
 func TestVarInMain(t *testing.T) {
 	gopClTest(t, `
 package main
@@ -817,6 +951,102 @@ func consume(xchg chan int) {
 `)
 }
 
+func TestSelectRecvAssign(t *testing.T) {
+	gopClTest(t, `
+func foo(ch, out chan int) {
+	var v int
+	var ok bool
+	select {
+	case v, ok = <-ch:
+		println(v, ok)
+	case x, ok := <-out:
+		println(x, ok)
+	case <-out:
+	}
+}
+`, `package main
+
+import fmt "fmt"
+
+func foo(ch chan int, out chan int) {
+	var v int
+	var ok bool
+	select {
+	case v, ok = <-ch:
+		fmt.Println(v, ok)
+	case x, ok := <-out:
+		fmt.Println(x, ok)
+	case <-out:
+	}
+}
+`)
+}
+
+func TestMultiAssignCall(t *testing.T) {
+	gopClTest(t, `
+func f() (int, string) {
+	return 1, "a"
+}
+
+var a int
+var b string
+a, b = f()
+c, d := f()
+m := map[string]int{}
+v, ok := m["k"]
+ch := make(chan int)
+w, ok2 := <-ch
+println a, b, c, d, v, ok, w, ok2
+`, `package main
+
+import fmt "fmt"
+
+func f() (int, string) {
+	return 1, "a"
+}
+
+var a int
+var b string
+
+func main() {
+	a, b = f()
+	c, d := f()
+	m := map[string]int{}
+	v, ok := m["k"]
+	ch := make(chan int)
+	w, ok2 := <-ch
+	fmt.Println(a, b, c, d, v, ok, w, ok2)
+}
+`)
+}
+
+func TestTypeAssertCommaOk(t *testing.T) {
+	gopClTest(t, `
+import "fmt"
+
+var x interface{} = 1
+v, ok := x.(string)
+s := x.(int)
+var t fmt.Stringer
+t, ok = x.(fmt.Stringer)
+println v, ok, s, t
+`, `package main
+
+import fmt "fmt"
+
+var x interface {
+} = 1
+
+func main() {
+	v, ok := x.(string)
+	s := x.(int)
+	var t fmt.Stringer
+	t, ok = x.(fmt.Stringer)
+	fmt.Println(v, ok, s, t)
+}
+`)
+}
+
 func TestTypeSwitch(t *testing.T) {
 	gopClTest(t, `
 
@@ -852,6 +1082,48 @@ func foo(v interface {
 `)
 }
 
+func TestTypeSwitchMultiTypes(t *testing.T) {
+	gopClTest(t, `
+func describe(x interface{}) {
+	switch v := x.(type) {
+	case int:
+		println "int", v+1
+	case string:
+		println "string", len(v)
+	case bool, float64:
+		println "bool or float", v
+	default:
+		println "other", v
+	}
+	switch x.(type) {
+	case nil:
+		println "nil"
+	}
+}
+`, `package main
+
+import fmt "fmt"
+
+func describe(x interface {
+}) {
+	switch v := x.(type) {
+	case int:
+		fmt.Println("int", v+1)
+	case string:
+		fmt.Println("string", len(v))
+	case bool, float64:
+		fmt.Println("bool or float", v)
+	default:
+		fmt.Println("other", v)
+	}
+	switch x.(type) {
+	case nil:
+		fmt.Println("nil")
+	}
+}
+`)
+}
+
 func TestTypeSwitch2(t *testing.T) {
 	gopClTest(t, `
 
@@ -938,6 +1210,61 @@ type Bar interface {
 `)
 }
 
+func TestInterfaceAssign(t *testing.T) {
+	gopClTest(t, `
+import "fmt"
+
+type Stringer interface {
+	String() string
+}
+
+type Named interface {
+	Stringer
+	Name() string
+}
+
+type T int
+
+func (T) String() string { return "T" }
+
+func (T) Name() string { return "t" }
+
+var s Stringer = T(1)
+var n Named = T(2)
+s = n
+var f fmt.Stringer = s
+println s, n, f
+`, `package main
+
+import fmt "fmt"
+
+type Stringer interface {
+	String() string
+}
+type Named interface {
+	Stringer
+	Name() string
+}
+type T int
+
+func (T) String() string {
+	return "T"
+}
+func (T) Name() string {
+	return "t"
+}
+
+var s Stringer = T(1)
+var n Named = T(2)
+
+func main() {
+	s = n
+	var f fmt.Stringer = s
+	fmt.Println(s, n, f)
+}
+`)
+}
+
 func TestInterfaceExample(t *testing.T) {
 	gopClTest(t, `
 type Shape interface {
@@ -1161,6 +1488,80 @@ var c []byte = make([]byte, 0, 2)
 `)
 }
 
+func TestDefineVarReuse(t *testing.T) {
+	gopClTest(t, `
+func f() (int, error) {
+	return 1, nil
+}
+
+func g() {
+	a, err := f()
+	b, err := f()
+	println a, b, err
+}
+`, `package main
+
+import fmt "fmt"
+
+func f() (int, error) {
+	return 1, nil
+}
+func g() {
+	a, err := f()
+	b, err := f()
+	fmt.Println(a, b, err)
+}
+`)
+}
+
+func TestNilCompare(t *testing.T) {
+	gopClTest(t, `
+type Point struct{ X int }
+
+func p2e(p *Point) error {
+	return nil
+}
+
+var p *Point = nil
+var s []int = nil
+var m map[string]int
+var ch chan int
+var fn func()
+var e error
+if p == nil || s == nil || m == nil || ch == nil || fn == nil || e == nil {
+	println "nil"
+}
+e = p2e(p)
+println e != nil
+`, `package main
+
+import fmt "fmt"
+
+type Point struct {
+	X int
+}
+
+func p2e(p *Point) error {
+	return nil
+}
+
+var p *Point = nil
+var s []int = nil
+var m map[string]int
+var ch chan int
+var fn func()
+var e error
+
+func main() {
+	if p == nil || s == nil || m == nil || ch == nil || fn == nil || e == nil {
+		fmt.Println("nil")
+	}
+	e = p2e(p)
+	fmt.Println(e != nil)
+}
+`)
+}
+
 func TestVarDecl(t *testing.T) {
 	gopClTest(t, `
 var a int
@@ -1169,6 +1570,32 @@ var x, y = 1, "Hi"
 
 var a int
 var x, y = 1, "Hi"
+`)
+	gopClTest(t, `
+var (
+	a = 1
+	b = "x"
+)
+var c, d = 1, 2
+
+func main() {
+	var e int
+	var f, g = a, b
+	println(c, d, e, f, g)
+}
+`, `package main
+
+import fmt "fmt"
+
+var a = 1
+var b = "x"
+var c, d = 1, 2
+
+func main() {
+	var e int
+	var f, g = a, b
+	fmt.Println(c, d, e, f, g)
+}
 `)
 }
 
@@ -1560,6 +1987,70 @@ func main() {
 `)
 }
 
+func TestStructLitNested(t *testing.T) {
+	gopClTest(t, `
+type Point struct {
+	X, Y int
+}
+
+type Line struct {
+	A, B Point
+}
+
+p := Point{X: 1}
+q := Point{1, 2}
+l := Line{A: Point{1, 2}, B: Point{Y: 3}}
+r := &Line{B: Point{Y: 3}}
+println p, q, l, r
+`, `package main
+
+import fmt "fmt"
+
+type Point struct {
+	X int
+	Y int
+}
+type Line struct {
+	A Point
+	B Point
+}
+
+func main() {
+	p := Point{X: 1}
+	q := Point{1, 2}
+	l := Line{A: Point{1, 2}, B: Point{Y: 3}}
+	r := &Line{B: Point{Y: 3}}
+	fmt.Println(p, q, l, r)
+}
+`)
+}
+
+func TestArrayTypeLit(t *testing.T) {
+	gopClTest(t, `
+type Vec [3]int
+
+var a [3]int
+b := [3]int{1, 2, 3}
+c := [...]int{1, 2, 3}
+d := Vec{1: 2}
+println a, b, c, d, len(c)
+`, `package main
+
+import fmt "fmt"
+
+type Vec [3]int
+
+var a [3]int
+
+func main() {
+	b := [3]int{1, 2, 3}
+	c := [...]int{1, 2, 3}
+	d := Vec{1: 2}
+	fmt.Println(a, b, c, d, len(c))
+}
+`)
+}
+
 func TestStructType(t *testing.T) {
 	gopClTest(t, `
 type bar = foo
@@ -1594,13 +2085,43 @@ func main() {
 `)
 }
 
-func TestDeferGo(t *testing.T) {
+func TestAliasType(t *testing.T) {
 	gopClTest(t, `
-go println("Hi")
-defer println("Go+")
-`, `package main
+type MyInt = int
 
-import fmt "fmt"
+var a MyInt = 1
+var b int = a
+
+type foo struct{}
+type bar = foo
+
+func (b bar) M() {}
+func (b *bar) N() {}
+`, `package main
+
+type MyInt = int
+
+var a int = 1
+var b int = a
+
+type foo struct {
+}
+type bar = foo
+
+func (b foo) M() {
+}
+func (b *foo) N() {
+}
+`)
+}
+
+func TestDeferGo(t *testing.T) {
+	gopClTest(t, `
+go println("Hi")
+defer println("Go+")
+`, `package main
+
+import fmt "fmt"
 
 func main() {
 	go fmt.Println("Hi")
@@ -1609,6 +2130,59 @@ func main() {
 `)
 }
 
+func TestDeferArgs(t *testing.T) {
+	gopClTest(t, `
+func f(x int) {
+	defer println("a", x)
+	x++
+	defer func(v int) {
+		println(v)
+	}(x)
+}
+`, `package main
+
+import fmt "fmt"
+
+func f(x int) {
+	defer fmt.Println("a", x)
+	x++
+	defer func(v int) {
+		fmt.Println(v)
+	}(x)
+}
+`)
+}
+
+func TestGoStmt(t *testing.T) {
+	gopClTest(t, `
+func work(ch chan int, x int) {
+	ch <- x
+}
+
+ch := make(chan int)
+x := 1
+go work(ch, x)
+go func(v int) {
+	println(v)
+}(x)
+`, `package main
+
+import fmt "fmt"
+
+func work(ch chan int, x int) {
+	ch <- x
+}
+func main() {
+	ch := make(chan int)
+	x := 1
+	go work(ch, x)
+	go func(v int) {
+		fmt.Println(v)
+	}(x)
+}
+`)
+}
+
 func TestFor(t *testing.T) {
 	gopClTest(t, `
 a := [1, 3.4, 5]
@@ -1682,6 +2256,53 @@ func main() {
 `)
 }
 
+func TestRangeStmtKinds(t *testing.T) {
+	gopClTest(t, `
+xs := []int{1, 2}
+m := map[string]int{"a": 1}
+ch := make(chan int)
+for i := range xs {
+	println(i)
+}
+for k, v := range m {
+	println(k, v)
+}
+for _, v := range m {
+	println(v)
+}
+for i, c := range "hi" {
+	println(i, c)
+}
+for v := range ch {
+	println(v)
+}
+`, `package main
+
+import fmt "fmt"
+
+func main() {
+	xs := []int{1, 2}
+	m := map[string]int{"a": 1}
+	ch := make(chan int)
+	for i := range xs {
+		fmt.Println(i)
+	}
+	for k, v := range m {
+		fmt.Println(k, v)
+	}
+	for _, v := range m {
+		fmt.Println(v)
+	}
+	for i, c := range "hi" {
+		fmt.Println(i, c)
+	}
+	for v := range ch {
+		fmt.Println(v)
+	}
+}
+`)
+}
+
 func TestRangeStmtUDT(t *testing.T) {
 	gopClTest(t, `
 type foo struct {
@@ -2279,6 +2900,154 @@ func (M) Bar() {
 `)
 }
 
+func TestMethodCall(t *testing.T) {
+	gopClTest(t, `
+type T int
+
+func (p *T) Inc() { *p++ }
+
+func (t T) Get() int { return int(t) }
+
+var t T
+t.Inc()
+println t.Get()
+p := &t
+println p.Get()
+`, `package main
+
+import fmt "fmt"
+
+type T int
+
+func (p *T) Inc() {
+	*p++
+}
+func (t T) Get() int {
+	return int(t)
+}
+
+var t T
+
+func main() {
+	t.Inc()
+	fmt.Println(t.Get())
+	p := &t
+	fmt.Println(p.Get())
+}
+`)
+}
+
+func TestPromotedMethod(t *testing.T) {
+	gopClTest(t, `
+type A struct{}
+
+func (*A) Foo() {}
+
+type B struct {
+	*A
+}
+
+type C struct {
+	B
+}
+
+c := C{B{&A{}}}
+c.Foo()
+`, `package main
+
+type A struct {
+}
+
+func (*A) Foo() {
+}
+
+type B struct {
+	*A
+}
+type C struct {
+	B
+}
+
+func main() {
+	c := C{B{&A{}}}
+	c.Foo()
+}
+`)
+}
+
+func TestMethodValueExpr(t *testing.T) {
+	gopClTest(t, `
+type T struct{ n int }
+
+func (t T) Get() int { return t.n }
+
+func (p *T) Inc() { p.n++ }
+
+t := T{1}
+f := t.Get
+g := T.Get
+h := (*T).Inc
+i := t.Inc
+h(&t)
+i()
+println f(), g(t)
+`, `package main
+
+import fmt "fmt"
+
+type T struct {
+	n int
+}
+
+func (t T) Get() int {
+	return t.n
+}
+func (p *T) Inc() {
+	p.n++
+}
+func main() {
+	t := T{1}
+	f := t.Get
+	g := T.Get
+	h := (*T).Inc
+	i := t.Inc
+	h(&t)
+	i()
+	fmt.Println(f(), g(t))
+}
+`)
+}
+
+func TestMethodBeforeType(t *testing.T) {
+	gopClTest(t, `
+import "bytes"
+
+func (p *Point) Move(dx, dy int) {
+	p.X += dx
+	p.Y += dy
+}
+
+type Point struct {
+	X, Y int "json:\"pos\""
+	*bytes.Buffer
+}
+`, `package main
+
+import bytes "bytes"
+
+type Point struct {
+	X int "json:\"pos\""
+	Y int "json:\"pos\""
+	*bytes.Buffer
+}
+
+func (p *Point) Move(dx int, dy int) {
+	p.X += dx
+	p.Y += dy
+}
+`)
+}
+
 func TestOverloadOp(t *testing.T) {
 	gopClTest(t, `
 type foo struct {
@@ -2368,6 +3137,26 @@ var a = round(1.2)
 import math "math"
 
 var a = math.Round(1.2)
+`)
+	gopClTest(t, `import . "fmt"
+
+func Println(a ...interface{}) {}
+
+func main() {
+	Println("Hi")
+	Sprintf("%d", 1)
+}
+`, `package main
+
+import fmt "fmt"
+
+func Println(a ...interface {
+}) {
+}
+func main() {
+	Println("Hi")
+	fmt.Sprintf("%d", 1)
+}
 `)
 }
 
@@ -2400,6 +3189,21 @@ import _ "fmt"
 
 func main() {
 }
+`)
+	gopClTest(t, `import _ "net/http/pprof"
+import "fmt"
+
+fmt.Println("Hi")
+`, `package main
+
+import (
+	fmt "fmt"
+	_ "net/http/pprof"
+)
+
+func main() {
+	fmt.Println("Hi")
+}
 `)
 }
 
@@ -2571,6 +3375,78 @@ func main() {
 `)
 }
 
+func TestSwitchMultiCase(t *testing.T) {
+	gopClTest(t, `
+x := 2
+switch x {
+case 1:
+	println "one"
+case 2, 3:
+	println "two or three"
+	fallthrough
+default:
+	println "other"
+}
+switch {
+case x > 1:
+	println "big"
+}
+`, `package main
+
+import fmt "fmt"
+
+func main() {
+	x := 2
+	switch x {
+	case 1:
+		fmt.Println("one")
+	case 2, 3:
+		fmt.Println("two or three")
+		fallthrough
+	default:
+		fmt.Println("other")
+	}
+	switch {
+	case x > 1:
+		fmt.Println("big")
+	}
+}
+`)
+}
+
+func TestGotoLabel(t *testing.T) {
+	gopClTest(t, `
+func foo() {
+	i := 0
+L:
+	if i < 3 {
+		i++
+		goto L
+	}
+	goto End
+	println "skip"
+End:
+	println i
+}
+`, `package main
+
+import fmt "fmt"
+
+func foo() {
+	i := 0
+L:
+	if i < 3 {
+		i++
+		goto L
+	}
+	goto End
+	fmt.Println("skip")
+End:
+	fmt.Println(i)
+}
+`)
+}
+
 func TestBranchStmt(t *testing.T) {
 	gopClTest(t, `
 	a := [1, 3.4, 5]
@@ -2602,6 +3478,31 @@ label:
 `)
 }
 
+func TestBreakLabeledSwitch(t *testing.T) {
+	gopClTest(t, `
+func foo(v int) {
+L:
+	switch v {
+	case 1:
+		for {
+			break L
+		}
+	}
+}
+`, `package main
+
+func foo(v int) {
+L:
+	switch v {
+	case 1:
+		for {
+			break L
+		}
+	}
+}
+`)
+}
+
 func TestReturn(t *testing.T) {
 	gopClTest(t, `
 func foo(format string, args ...interface{}) (int, error) {
@@ -2642,33 +3543,314 @@ func main() {
 `)
 }
 
-func TestClosure(t *testing.T) {
-	gopClTest(t, `import "fmt"
-
-func(v string) {
-	fmt.Println(v)
-}("Hello")
+func TestReturnNamedResults(t *testing.T) {
+	gopClTest(t, `
+func foo() (n int, err error) {
+	n = 1
+	if n > 0 {
+		n := 2
+		_ = n
+	}
+	return
+}
 `, `package main
 
-import fmt "fmt"
-
-func main() {
-	func(v string) {
-		fmt.Println(v)
-	}("Hello")
+func foo() (n int, err error) {
+	n = 1
+	if n > 0 {
+		n := 2
+		_ = n
+	}
+	return
 }
 `)
 }
 
-func TestFunc(t *testing.T) {
-	gopClTest(t, `func foo(format string, a [10]int, args ...interface{}) {
+func TestVariadicFunc(t *testing.T) {
+	gopClTest(t, `
+func sum(nums ...int) int {
+	t := 0
+	for _, n := range nums {
+		t += n
+	}
+	return t
 }
 
-func main() {
-}`, `package main
+xs := []int{1, 2}
+println sum(1, 2, 3), sum(xs...), sum()
+`, `package main
 
-func foo(format string, a [10]int, args ...interface {
-}) {
+import fmt "fmt"
+
+func sum(nums ...int) int {
+	t := 0
+	for _, n := range nums {
+		t += n
+	}
+	return t
+}
+func main() {
+	xs := []int{1, 2}
+	fmt.Println(sum(1, 2, 3), sum(xs...), sum())
+}
+`)
+}
+
+func TestMutualRecursion(t *testing.T) {
+	gopClTest(t, `
+func isEven(n int) bool {
+	if n == 0 {
+		return true
+	}
+	return isOdd(n - 1)
+}
+
+func isOdd(n int) bool {
+	if n == 0 {
+		return false
+	}
+	return isEven(n - 1)
+}
+
+println isEven(4)
+`, `package main
+
+import fmt "fmt"
+
+func isEven(n int) bool {
+	if n == 0 {
+		return true
+	}
+	return isOdd(n - 1)
+}
+func isOdd(n int) bool {
+	if n == 0 {
+		return false
+	}
+	return isEven(n - 1)
+}
+func main() {
+	fmt.Println(isEven(4))
+}
+`)
+}
+
+func TestPanicRecover(t *testing.T) {
+	gopClTest(t, `
+import "fmt"
+
+func safe() (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("recovered: %v", e)
+		}
+	}()
+	panic("boom")
+}
+
+println recover()
+`, `package main
+
+import fmt "fmt"
+
+func safe() (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("recovered: %v", e)
+		}
+	}()
+	panic("boom")
+}
+func main() {
+	fmt.Println(recover())
+}
+`)
+}
+
+func TestClosureCapture(t *testing.T) {
+	gopClTest(t, `
+func counter() func() int {
+	n := 0
+	return func() int {
+		n++
+		return n
+	}
+}
+
+c := counter()
+c()
+println c()
+var fns []func()
+for i := 0; i < 3; i++ {
+	i := i
+	fns = append(fns, func() {
+		println i
+	})
+}
+`, `package main
+
+import fmt "fmt"
+
+func counter() func() int {
+	n := 0
+	return func() int {
+		n++
+		return n
+	}
+}
+func main() {
+	c := counter()
+	c()
+	fmt.Println(c())
+	var fns []func()
+	for i := 0; i < 3; i++ {
+		i := i
+		fns = append(fns, func() {
+			fmt.Println(i)
+		})
+	}
+}
+`)
+}
+
+func TestFuncLitCall(t *testing.T) {
+	gopClTest(t, `
+sq := (func(x int) int { return x * x })(5)
+g := func() {
+	println "g"
+}
+g()
+h := func(x int) int { return x + 1 }
+k := func(x int) int { return x - 1 }
+println sq, h(1), k(1)
+`, `package main
+
+import fmt "fmt"
+
+func main() {
+	sq := func(x int) int {
+		return x * x
+	}(5)
+	g := func() {
+		fmt.Println("g")
+	}
+	g()
+	h := func(x int) int {
+		return x + 1
+	}
+	k := func(x int) int {
+		return x - 1
+	}
+	fmt.Println(sq, h(1), k(1))
+}
+`)
+}
+
+func TestMultiFiles(t *testing.T) {
+	files := map[string]string{"a.gop": `
+import s "strings"
+
+type Point struct {
+	X, Y int
+}
+
+func upper(v string) string {
+	return s.ToUpper(v)
+}
+`, "b.gop": `
+import "strings"
+
+func show(p Point) {
+	println upper(strings.TrimSpace(" hi ")), p.X
+}
+`}
+	header := `package main
+
+import (
+	fmt "fmt"
+	strings "strings"
+)
+
+type Point struct {
+	X int
+	Y int
+}
+
+`
+	upper := `func upper(v string) string {
+	return strings.ToUpper(v)
+}
+`
+	show := `func show(p Point) {
+	fmt.Println(upper(strings.TrimSpace(" hi ")), p.X)
+}
+`
+	gopClTestFiles(t, baseConf.Ensure(), "", files, header+upper+show, header+show+upper)
+}
+
+func TestCompileSource(t *testing.T) {
+	conf := *baseConf.Ensure()
+	gopClTestFiles(t, &conf, "", map[string]string{"a.gop": `
+func add(a, b int) int {
+	return a + b
+}
+
+println add(1, 2)
+`}, `package main
+
+import fmt "fmt"
+
+func add(a int, b int) int {
+	return a + b
+}
+func main() {
+	fmt.Println(add(1, 2))
+}
+`)
+	_, err := cl.CompileSource("", map[string]string{"a.gop": "x := (1\n"}, &conf)
+	if list, ok := err.(scanner.ErrorList); !ok || list[0].Pos.Filename != "a.gop" || list[0].Pos.Line != 1 {
+		t.Fatal("CompileSource: parse error expected -", err)
+	}
+	_, err = cl.CompileSource("", map[string]string{
+		"a.gop": "package foo\n", "b.gop": "package bar\n",
+	}, &conf)
+	if err == nil || err.Error() != "b.gop: found package bar, expected foo" {
+		t.Fatal("CompileSource:", err)
+	}
+	conf.Fset = nil
+	_, err = cl.CompileSource("", map[string]string{"a.gop": "println 1\n"}, &conf)
+	if err != nil || conf.Fset != nil {
+		t.Fatal("CompileSource:", err, conf.Fset)
+	}
+}
+
+func TestClosure(t *testing.T) {
+	gopClTest(t, `import "fmt"
+
+func(v string) {
+	fmt.Println(v)
+}("Hello")
+`, `package main
+
+import fmt "fmt"
+
+func main() {
+	func(v string) {
+		fmt.Println(v)
+	}("Hello")
+}
+`)
+}
+
+func TestFunc(t *testing.T) {
+	gopClTest(t, `func foo(format string, a [10]int, args ...interface{}) {
+}
+
+func main() {
+}`, `package main
+
+func foo(format string, a [10]int, args ...interface {
+}) {
 }
 func main() {
 }
@@ -3047,3 +4229,1778 @@ func newRepo() Repo {
 `, "")
 	}
 }
+
+func TestTypeConversion(t *testing.T) {
+	gopClTest(t, `
+type Celsius float64
+
+type Temp float64
+
+n := 3
+a := int(3.0)
+b := float64(n)
+c := string(rune(65))
+d := []byte("hi")
+e := string(d)
+t := Temp(Celsius(1.5))
+println a, b, c, d, e, t
+`, `package main
+
+import fmt "fmt"
+
+type Celsius float64
+type Temp float64
+
+func main() {
+	n := 3
+	a := int(3.0)
+	b := float64(n)
+	c := string(rune(65))
+	d := []byte("hi")
+	e := string(d)
+	t := Temp(Celsius(1.5))
+	fmt.Println(a, b, c, d, e, t)
+}
+`)
+}
+
+func TestAppendBuiltin(t *testing.T) {
+	gopClTest(t, `
+var s []int
+s = append(s, 1)
+s = append(s, 2, 3, 4)
+t := []int{5, 6}
+s = append(s, t...)
+b := append([]byte("hi"), " there"...)
+println s, len(s), string(b)
+`, `package main
+
+import fmt "fmt"
+
+var s []int
+
+func main() {
+	s = append(s, 1)
+	s = append(s, 2, 3, 4)
+	t := []int{5, 6}
+	s = append(s, t...)
+	b := append([]byte("hi"), " there"...)
+	fmt.Println(s, len(s), string(b))
+}
+`)
+}
+
+func TestMakeBuiltin(t *testing.T) {
+	gopClTest(t, `
+a := make([]int, 5)
+b := make([]int, 0, 10)
+c := make(map[string]int)
+d := make(map[string]int, 8)
+e := make(chan int, 4)
+f := make(chan int)
+println a, b, c, d, e, f
+`, `package main
+
+import fmt "fmt"
+
+func main() {
+	a := make([]int, 5)
+	b := make([]int, 0, 10)
+	c := make(map[string]int)
+	d := make(map[string]int, 8)
+	e := make(chan int, 4)
+	f := make(chan int)
+	fmt.Println(a, b, c, d, e, f)
+}
+`)
+}
+
+func TestLenCap(t *testing.T) {
+	gopClTest(t, `
+var s []int
+var m map[string]int
+var a [3]int
+c := make(chan int, 2)
+println len("hi"), len(s), len(a), len(m), len(c)
+println cap(s), cap(a), cap(c)
+`, `package main
+
+import fmt "fmt"
+
+var s []int
+var m map[string]int
+var a [3]int
+
+func main() {
+	c := make(chan int, 2)
+	fmt.Println(len("hi"), len(s), len(a), len(m), len(c))
+	fmt.Println(cap(s), cap(a), cap(c))
+}
+`)
+}
+
+func TestLenArrayPtr(t *testing.T) {
+	gopClTest(t, `
+var p *[4]int
+var q *[]int
+println len(p), cap(p)
+`, `package main
+
+import fmt "fmt"
+
+var p *[4]int
+var q *[]int
+
+func main() {
+	fmt.Println(len(p), cap(p))
+}
+`)
+}
+
+func TestSlice3Index(t *testing.T) {
+	gopClTest(t, `
+a := [5]int{1, 2, 3, 4, 5}
+s := a[1:3:4]
+t := s[:1:2]
+u := s[1:]
+println s, t, u, cap(s)
+`, `package main
+
+import fmt "fmt"
+
+func main() {
+	a := [5]int{1, 2, 3, 4, 5}
+	s := a[1:3:4]
+	t := s[:1:2]
+	u := s[1:]
+	fmt.Println(s, t, u, cap(s))
+}
+`)
+}
+
+func TestPointerAddrDeref(t *testing.T) {
+	gopClTest(t, `
+type Point struct {
+	X, Y int
+}
+
+x := 1
+p := &x
+*p = 5
+y := *p
+q := &Point{1, 2}
+println x, y, q.X
+`, `package main
+
+import fmt "fmt"
+
+type Point struct {
+	X int
+	Y int
+}
+
+func main() {
+	x := 1
+	p := &x
+	*p = 5
+	y := *p
+	q := &Point{1, 2}
+	fmt.Println(x, y, q.X)
+}
+`)
+}
+
+func TestAddrOfIndex(t *testing.T) {
+	gopClTest(t, `
+func foo() {
+	a := [2]int{1, 2}
+	p := &a[1]
+	q := &(a)
+	println *p, *q
+}
+`, `package main
+
+import fmt "fmt"
+
+func foo() {
+	a := [2]int{1, 2}
+	p := &a[1]
+	q := &a
+	fmt.Println(*p, *q)
+}
+`)
+}
+
+func TestAddrOfFieldsAndElems(t *testing.T) {
+	gopClTest(t, `
+import "os"
+
+type T struct {
+	X int
+	A [2]int
+	P *T
+}
+
+func foo() {
+	var t T
+	a := []int{1}
+	arr := [2]int{}
+	pa := &arr
+	ps := []T{t}
+	p1, p2, p3, p4, p5 := &t.X, &t.A[1], &a[0], &arr[1], &pa[0]
+	p6, p7, p8, p9 := &(t.P).X, &ps[0].X, &(T{X: 1}), &os.Args
+	println p1, p2, p3, p4, p5, p6, p7, p8, p9
+}
+`, `package main
+
+import (
+	fmt "fmt"
+	os "os"
+)
+
+type T struct {
+	X int
+	A [2]int
+	P *T
+}
+
+func foo() {
+	var t T
+	a := []int{1}
+	arr := [2]int{}
+	pa := &arr
+	ps := []T{t}
+	p1, p2, p3, p4, p5 := &t.X, &t.A[1], &a[0], &arr[1], &pa[0]
+	p6, p7, p8, p9 := &t.P.X, &ps[0].X, &T{X: 1}, &os.Args
+	fmt.Println(p1, p2, p3, p4, p5, p6, p7, p8, p9)
+}
+`)
+}
+
+func TestPointerFieldAccess(t *testing.T) {
+	gopClTest(t, `
+type Inner struct {
+	Field int
+}
+
+type Point struct {
+	X, Y  int
+	Inner *Inner
+}
+
+p := &Point{1, 2, &Inner{3}}
+p.X = 9
+p.Inner.Field = 7
+println p.X, p.Inner.Field
+`, `package main
+
+import fmt "fmt"
+
+type Inner struct {
+	Field int
+}
+type Point struct {
+	X     int
+	Y     int
+	Inner *Inner
+}
+
+func main() {
+	p := &Point{1, 2, &Inner{3}}
+	p.X = 9
+	p.Inner.Field = 7
+	fmt.Println(p.X, p.Inner.Field)
+}
+`)
+}
+
+func TestChanSendRecv(t *testing.T) {
+	gopClTest(t, `
+ch := make(chan int, 2)
+ch <- 1
+v := <-ch
+w, ok := <-ch
+println v, w, ok
+`, `package main
+
+import fmt "fmt"
+
+func main() {
+	ch := make(chan int, 2)
+	ch <- 1
+	v := <-ch
+	w, ok := <-ch
+	fmt.Println(v, w, ok)
+}
+`)
+}
+
+func TestCloseChan(t *testing.T) {
+	gopClTest(t, `
+ch := make(chan int)
+close(ch)
+var s chan<- int = make(chan int)
+close(s)
+`, `package main
+
+func main() {
+	ch := make(chan int)
+	close(ch)
+	var s chan<- int = make(chan int)
+	close(s)
+}
+`)
+}
+
+func TestChanDirection(t *testing.T) {
+	gopClTest(t, `
+func producer(out chan<- int) {
+	out <- 1
+}
+
+func consumer(in <-chan int) int {
+	return <-in
+}
+
+ch := make(chan int, 1)
+producer(ch)
+println consumer(ch)
+`, `package main
+
+import fmt "fmt"
+
+func producer(out chan<- int) {
+	out <- 1
+}
+func consumer(in <-chan int) int {
+	return <-in
+}
+func main() {
+	ch := make(chan int, 1)
+	producer(ch)
+	fmt.Println(consumer(ch))
+}
+`)
+}
+
+func TestBitOps(t *testing.T) {
+	gopClTest(t, `
+a, b := 12, 10
+var u uint = 2
+println a&b, a|b, a^b, a&^b, a<<u, a>>1, a%b, ^a
+`, `package main
+
+import fmt "fmt"
+
+func main() {
+	a, b := 12, 10
+	var u uint = 2
+	fmt.Println(a&b, a|b, a^b, a&^b, a<<u, a>>1, a%b, ^a)
+}
+`)
+}
+
+func TestShiftFloatCount(t *testing.T) {
+	gopClTest(t, `
+func foo() {
+	var a int = 7
+	b := a % 2.0
+	c := 1 << 3.0
+	println b, c
+}
+`, `package main
+
+import fmt "fmt"
+
+func foo() {
+	var a int = 7
+	b := a % 2.0
+	c := 1 << 3.0
+	fmt.Println(b, c)
+}
+`)
+}
+
+func TestUntypedConstExpr(t *testing.T) {
+	gopClTest(t, `
+var x float64 = 1
+y := 2 * 3.0
+const n = 2
+var a [n * 2]int
+z := x > 0
+println x, y, a, z, 1 < 2.5
+`, `package main
+
+import fmt "fmt"
+
+var x float64 = 1
+
+func main() {
+	y := 2 * 3.0
+	const n = 2
+	var a [4]int
+	z := x > 0
+	fmt.Println(x, y, a, z, true)
+}
+`)
+}
+
+func TestComparisonOps(t *testing.T) {
+	gopClTest(t, `
+a, b := 1, 2
+var s []int
+var m map[string]int
+var f func()
+println a == b, a != b, a < b, "x" <= "y", s == nil, m != nil, f == nil
+`, `package main
+
+import fmt "fmt"
+
+func main() {
+	a, b := 1, 2
+	var s []int
+	var m map[string]int
+	var f func()
+	fmt.Println(a == b, a != b, a < b, true, s == nil, m != nil, f == nil)
+}
+`)
+}
+
+func TestShortCircuitLogic(t *testing.T) {
+	gopClTest(t, `
+func check(name string, v bool) bool {
+	println "eval", name
+	return v
+}
+
+if check("a", false) && check("b", true) {
+	println "both"
+}
+if check("c", true) || check("d", false) {
+	println "either"
+}
+`, `package main
+
+import fmt "fmt"
+
+func check(name string, v bool) bool {
+	fmt.Println("eval", name)
+	return v
+}
+func main() {
+	if check("a", false) && check("b", true) {
+		fmt.Println("both")
+	}
+	if check("c", true) || check("d", false) {
+		fmt.Println("either")
+	}
+}
+`)
+}
+
+func TestIncDecOperands(t *testing.T) {
+	gopClTest(t, `
+type P struct {
+	N int
+}
+
+i := 0
+i++
+i--
+p := P{}
+p.N++
+s := []int{1, 2}
+s[0]++
+m := map[string]int{}
+m["a"]++
+println i, p, s, m
+`, `package main
+
+import fmt "fmt"
+
+type P struct {
+	N int
+}
+
+func main() {
+	i := 0
+	i++
+	i--
+	p := P{}
+	p.N++
+	s := []int{1, 2}
+	s[0]++
+	m := map[string]int{}
+	m["a"]++
+	fmt.Println(i, p, s, m)
+}
+`)
+}
+
+func TestIncDecSideEffects(t *testing.T) {
+	gopClTest(t, `
+import "strconv"
+
+func inc(a []int) (err error) {
+	a[strconv.Atoi("1")?]++
+	return
+}
+`, `package main
+
+import strconv "strconv"
+
+func inc(a []int) (err error) {
+	var _autoGo_1 int
+	{
+		var _gop_err error
+		_autoGo_1, _gop_err = strconv.Atoi("1")
+		if _gop_err != nil {
+			return _gop_err
+		}
+		goto _autoGo_2
+	_autoGo_2:
+	}
+	a[_autoGo_1]++
+	return
+}
+`)
+}
+
+func TestCompoundAssignOps(t *testing.T) {
+	gopClTest(t, `
+a := 10
+a += 1
+a -= 2
+a *= 3
+a /= 2
+a %= 5
+a &= 7
+a |= 8
+a ^= 1
+a <<= 2
+a >>= 1
+a &^= 4
+m := map[string]int{}
+m["k"] += 5
+s := "a"
+s += "b"
+println a, m, s
+`, `package main
+
+import fmt "fmt"
+
+func main() {
+	a := 10
+	a += 1
+	a -= 2
+	a *= 3
+	a /= 2
+	a %= 5
+	a &= 7
+	a |= 8
+	a ^= 1
+	a <<= 2
+	a >>= 1
+	a &^= 4
+	m := map[string]int{}
+	m["k"] += 5
+	s := "a"
+	s += "b"
+	fmt.Println(a, m, s)
+}
+`)
+}
+
+func TestAssignOpSideEffects(t *testing.T) {
+	gopClTest(t, `
+import "strconv"
+
+func add(a []int) (err error) {
+	a[strconv.Atoi("0")?] += 5
+	return
+}
+`, `package main
+
+import strconv "strconv"
+
+func add(a []int) (err error) {
+	var _autoGo_1 int
+	{
+		var _gop_err error
+		_autoGo_1, _gop_err = strconv.Atoi("0")
+		if _gop_err != nil {
+			return _gop_err
+		}
+		goto _autoGo_2
+	_autoGo_2:
+	}
+	a[_autoGo_1] += 5
+	return
+}
+`)
+}
+
+func TestAssignConstSideEffects(t *testing.T) {
+	gopClTest(t, `
+import "strconv"
+
+func set(a []int8) (err error) {
+	a[strconv.Atoi("0")?] = 5
+	return
+}
+`, `package main
+
+import strconv "strconv"
+
+func set(a []int8) (err error) {
+	var _autoGo_1 int
+	{
+		var _gop_err error
+		_autoGo_1, _gop_err = strconv.Atoi("0")
+		if _gop_err != nil {
+			return _gop_err
+		}
+		goto _autoGo_2
+	_autoGo_2:
+	}
+	a[_autoGo_1] = 5
+	return
+}
+`)
+}
+
+func TestRecursiveTypes(t *testing.T) {
+	gopClTest(t, `
+type Node struct {
+	val  int
+	next *Node
+}
+
+type A struct {
+	b *B
+}
+
+type B struct {
+	a *A
+}
+
+n := &Node{val: 1, next: &Node{val: 2}}
+for p := n; p != nil; p = p.next {
+	println p.val
+}
+x := &A{b: &B{}}
+x.b.a = x
+`, `package main
+
+import fmt "fmt"
+
+type Node struct {
+	val  int
+	next *Node
+}
+type A struct {
+	b *B
+}
+type B struct {
+	a *A
+}
+
+func main() {
+	n := &Node{val: 1, next: &Node{val: 2}}
+	for p := n; p != nil; p = p.next {
+		fmt.Println(p.val)
+	}
+	x := &A{b: &B{}}
+	x.b.a = x
+}
+`)
+}
+
+func TestMethodReceivers(t *testing.T) {
+	gopClTest(t, `
+type T struct{}
+
+func (t T) A() {
+}
+
+func (p *T) B() {
+}
+
+func (T) C() {
+}
+`, `package main
+
+type T struct {
+}
+
+func (t T) A() {
+}
+func (p *T) B() {
+}
+func (T) C() {
+}
+`)
+}
+
+func TestBlankRecvAndParams(t *testing.T) {
+	gopClTest(t, `
+type T struct{}
+
+func (_ T) M(_ int, _ int) int {
+	return 1
+}
+
+func f(_ int, b string, _ float64) string {
+	return b
+}
+
+func g(_, _ int) {
+}
+
+var t T
+println t.M(1, 2), f(1, "hi", 2.0)
+g(1, 2)
+`, `package main
+
+import fmt "fmt"
+
+type T struct {
+}
+
+func (_ T) M(_ int, _ int) int {
+	return 1
+}
+func f(_ int, b string, _ float64) string {
+	return b
+}
+func g(_ int, _ int) {
+}
+
+var t T
+
+func main() {
+	fmt.Println(t.M(1, 2), f(1, "hi", 2.0))
+	g(1, 2)
+}
+`)
+}
+
+func TestMultiValueCallArgs(t *testing.T) {
+	gopClTest(t, `
+func f() (int, string) {
+	return 1, "a"
+}
+
+func g(a int, b string) string {
+	return b
+}
+
+println g(f())
+`, `package main
+
+import fmt "fmt"
+
+func f() (int, string) {
+	return 1, "a"
+}
+func g(a int, b string) string {
+	return b
+}
+func main() {
+	fmt.Println(g(f()))
+}
+`)
+}
+
+func TestNewBuiltin(t *testing.T) {
+	conf := *baseConf.Ensure()
+	conf.NewBuiltin = func(pkg gox.PkgImporter, conf *gox.Config) *types.Package {
+		builtin := types.NewPackage("", "")
+		gox.InitBuiltin(pkg, builtin, conf)
+		return builtin
+	}
+	gopClTestFiles(t, &conf, "", map[string]string{"a.gop": `
+a := []int{1, 2}
+a = append(a, len(a))
+`}, `package main
+
+func main() {
+	a := []int{1, 2}
+	a = append(a, len(a))
+}
+`)
+	_, err := cl.CompileSource("", map[string]string{"a.gop": `
+func main() {
+	printf("Hi\n")
+}
+`}, &conf)
+	if err == nil || err.Error() != "a.gop:3:2: undefined: printf" {
+		t.Fatal("CompileSource:", err)
+	}
+	conf.NewBuiltin = func(pkg gox.PkgImporter, conf *gox.Config) *types.Package {
+		return types.NewPackage("", "") // no builtins at all
+	}
+	_, err = cl.CompileSource("", map[string]string{"a.gop": `
+func f(a, b int) {
+}
+
+func g(s string) {
+}
+
+func main() {
+	f(1, 2)
+	g("Hi")
+}
+`}, &conf)
+	if err != nil {
+		t.Fatal("CompileSource:", err)
+	}
+}
+
+func TestMaxErrors(t *testing.T) {
+	src := map[string]string{"a.gop": `
+func main() {
+	a := undefined1
+	b := undefined2
+	c := undefined3
+}
+`}
+	conf := *baseConf.Ensure()
+	_, err := cl.CompileSource("", src, &conf)
+	if e, ok := err.(*cl.Errors); !ok || len(e.Errs) != 3 {
+		t.Fatal("CompileSource:", err)
+	}
+	conf.MaxErrors = 2
+	_, err = cl.CompileSource("", src, &conf)
+	if err == nil || err.Error() != "a.gop:3:7: undefined: undefined1\na.gop:4:7: undefined: undefined2" {
+		t.Fatal("CompileSource:", err)
+	}
+	src = map[string]string{
+		"a.gop": "package main\n\nfunc f() {\n\ta := undefined1\n}\n",
+		"b.gop": "package main\n\nfunc g() {\n\tb := undefined2\n}\n",
+		"c.gop": "package main\n\nfunc h() {\n\tc := undefined3\n}\n",
+	}
+	for i := 0; i < 5; i++ { // files are compiled in no particular order
+		_, err = cl.CompileSource("", src, &conf)
+		if err == nil || err.Error() != "a.gop:4:7: undefined: undefined1\nb.gop:4:7: undefined: undefined2" {
+			t.Fatal("CompileSource:", err)
+		}
+	}
+}
+
+func TestConstStringConcat(t *testing.T) {
+	gopClTest(t, `
+const greeting = "hello" + " " + "world"
+const prefix = "> "
+const line = prefix + greeting
+
+println line
+`, `package main
+
+import fmt "fmt"
+
+const greeting = "hello" + " " + "world"
+const prefix = "> "
+const line = prefix + greeting
+
+func main() {
+	fmt.Println(line)
+}
+`)
+}
+
+func TestCopyBuiltin(t *testing.T) {
+	gopClTest(t, `
+a := []int{1, 2, 3}
+b := make([]int, 2)
+n := copy(b, a)
+buf := make([]byte, 5)
+m := copy(buf, "hello")
+println n, m, b, buf
+`, `package main
+
+import fmt "fmt"
+
+func main() {
+	a := []int{1, 2, 3}
+	b := make([]int, 2)
+	n := copy(b, a)
+	buf := make([]byte, 5)
+	m := copy(buf, "hello")
+	fmt.Println(n, m, b, buf)
+}
+`)
+}
+
+func TestDeleteBuiltin(t *testing.T) {
+	gopClTest(t, `
+m := map[string]int{"a": 1}
+delete(m, "a")
+delete(m, "none")
+var n map[string]int
+delete(n, "x")
+println len(m)
+`, `package main
+
+import fmt "fmt"
+
+func main() {
+	m := map[string]int{"a": 1}
+	delete(m, "a")
+	delete(m, "none")
+	var n map[string]int
+	delete(n, "x")
+	fmt.Println(len(m))
+}
+`)
+}
+
+func TestNewBuiltinCall(t *testing.T) {
+	gopClTest(t, `
+type Point struct {
+	X, Y int
+}
+
+p := new(int)
+*p = 3
+q := new(Point)
+q.X = 1
+(*q).Y = 2
+println *p, *q, &(*q).X
+`, `package main
+
+import fmt "fmt"
+
+type Point struct {
+	X int
+	Y int
+}
+
+func main() {
+	p := new(int)
+	*p = 3
+	q := new(Point)
+	q.X = 1
+	(*q).Y = 2
+	fmt.Println(*p, *q, &(*q).X)
+}
+`)
+}
+
+func TestConstBackwardRefs(t *testing.T) {
+	gopClTest(t, `
+const (
+	A = 1
+	B = A + 1
+	C = B * 2
+)
+
+func main() {
+	const (
+		X = C + 1
+		Y = X * 2
+	)
+	println A, B, C, X, Y
+}
+`, `package main
+
+import fmt "fmt"
+
+const (
+	A = 1
+	B = A + 1
+	C = B * 2
+)
+
+func main() {
+	const (
+		X = C + 1
+		Y = X * 2
+	)
+	fmt.Println(A, B, C, X, Y)
+}
+`)
+}
+
+func TestLibraryPackage(t *testing.T) {
+	gopClTestFiles(t, baseConf.Ensure(), "github.com/goplus/gop/foo", map[string]string{"a.gop": `package foo
+
+func Add(a, b int) int {
+	return double(a) + b
+}
+
+func double(a int) int {
+	return a * 2
+}
+
+func unused() {
+}
+`}, `package foo
+
+func Add(a int, b int) int {
+	return double(a) + b
+}
+func double(a int) int {
+	return a * 2
+}
+func unused() {
+}
+`)
+}
+
+func TestCheckUnusedImports(t *testing.T) {
+	conf := *baseConf.Ensure()
+	conf.CheckUnusedImports = true
+	_, err := cl.CompileSource("", map[string]string{"a.gop": `
+import (
+	"fmt"
+	"strconv"
+	str "strings"
+	_ "os"
+)
+
+func main() {
+	var b str.Builder
+	fmt.Println(b.String())
+}
+`, "b.gop": `
+import "strconv"
+
+func itoa(n int) string {
+	return strconv.Itoa(n)
+}
+`}, &conf)
+	if err == nil || err.Error() != `a.gop:4:2: "strconv" imported but not used` {
+		t.Fatal("CompileSource:", err)
+	}
+	_, err = cl.CompileSource("", map[string]string{"a.gop": `
+import str "strings"
+
+func main() {
+}
+`}, &conf)
+	if err == nil || err.Error() != `a.gop:2:8: "strings" imported but not used as str` {
+		t.Fatal("CompileSource:", err)
+	}
+	_, err = cl.CompileSource("", map[string]string{"a.gop": `
+import (
+	. "strconv"
+	. "strings"
+)
+
+func main() {
+	println ToUpper("a")
+}
+`}, &conf)
+	if err == nil || err.Error() != `a.gop:3:2: "strconv" imported but not used` {
+		t.Fatal("CompileSource:", err)
+	}
+	foo, err := cl.CompileSource("example.com/foo-v2", map[string]string{"foo.gop": `package foo
+
+func Add(a, b int) int {
+	return a + b
+}
+`}, &conf)
+	if err != nil {
+		t.Fatal("CompileSource foo:", err)
+	}
+	conf.CompiledPkgs = map[string]*gox.Package{"example.com/foo-v2": foo}
+	_, err = cl.CompileSource("", map[string]string{"a.gop": `
+import "example.com/foo-v2"
+
+func main() {
+	println foo.Add(1, 2)
+}
+`, "b.gop": `
+import "example.com/foo-v2"
+`}, &conf)
+	if err == nil || err.Error() != `b.gop:2:8: "example.com/foo-v2" imported but not used` {
+		t.Fatal("CompileSource:", err)
+	}
+}
+
+func TestCheckUnusedVars(t *testing.T) {
+	conf := *baseConf.Ensure()
+	conf.CheckUnusedVars = true
+	_, err := cl.CompileSource("", map[string]string{"a.gop": `
+type T struct {
+	x int
+}
+
+func (t T) f(a int) (r int) {
+	x := 1
+	var y int
+	z := 2
+	println z
+	var p T
+	p.x = 1
+	fn := func(b int) {
+		c := 1
+		println a
+	}
+	fn(1)
+	var e interface{} = 1
+	switch v := e.(type) {
+	case int:
+		println v
+	case string:
+	}
+	if n, ok := e.(int); ok {
+	}
+	for i, w := range []int{1} {
+		println w
+	}
+	for k := range map[string]int{} {
+	}
+	switch u := e.(type) {
+	case int:
+	default:
+	}
+	m := 0
+	m++
+	s := 1
+	s += 2
+	q := 0
+	func() {
+		q++
+	}()
+	apply := func(f func(int) int) {}
+	apply(v => 100)
+	return
+}
+`}, &conf)
+	expected := `a.gop:14:3: c declared but not used
+a.gop:7:2: x declared but not used
+a.gop:8:6: y declared but not used
+a.gop:24:5: n declared but not used
+a.gop:26:6: i declared but not used
+a.gop:29:6: k declared but not used
+a.gop:31:9: u declared but not used
+a.gop:35:2: m declared but not used
+a.gop:37:2: s declared but not used
+a.gop:39:2: q declared but not used`
+	if err == nil || err.Error() != expected {
+		t.Fatal("CompileSource:", err)
+	}
+}
+
+func TestStructTagLookup(t *testing.T) {
+	conf := *baseConf.Ensure()
+	pkg, err := cl.CompileSource("", map[string]string{"a.gop": `
+type User struct {
+	Name string ` + "`json:\"name\"`" + `
+	Age  int    "json:\"age,omitempty\""
+}
+`}, &conf)
+	if err != nil {
+		t.Fatal("CompileSource:", err)
+	}
+	typ := pkg.Types.Scope().Lookup("User").Type().Underlying().(*types.Struct)
+	if tag := reflect.StructTag(typ.Tag(0)).Get("json"); tag != "name" {
+		t.Fatal("Tag(0):", tag)
+	}
+	if tag := reflect.StructTag(typ.Tag(1)).Get("json"); tag != "age,omitempty" {
+		t.Fatal("Tag(1):", tag)
+	}
+}
+
+func TestAnonymousStruct(t *testing.T) {
+	gopClTest(t, `
+var p struct {
+	X, Y int
+}
+q := struct{ X, Y int }{X: 1}
+p = q
+r := struct {
+	X int
+}{X: 1}
+println p, q, r.X
+`, `package main
+
+import fmt "fmt"
+
+var p struct {
+	X int
+	Y int
+}
+
+func main() {
+	q := struct {
+		X int
+		Y int
+	}{X: 1}
+	p = q
+	r := struct {
+		X int
+	}{X: 1}
+	fmt.Println(p, q, r.X)
+}
+`)
+}
+
+func TestNamedFuncType(t *testing.T) {
+	gopClTest(t, `
+import (
+	"errors"
+	"fmt"
+)
+
+type Handler func(int) error
+
+type Logf func(format string, args ...interface{}) (int, error)
+
+func check(n int) error {
+	if n < 0 {
+		return errors.New("negative")
+	}
+	return nil
+}
+
+var h Handler = check
+h2 := Handler(func(n int) error {
+	return nil
+})
+var l Logf = fmt.Printf
+l("%d %d\n", 1, 2)
+println h(1), h2(-1)
+`, `package main
+
+import (
+	fmt "fmt"
+	errors "errors"
+)
+
+type Handler func(int) error
+type Logf func(format string, args ...interface {
+}) (int, error)
+
+func check(n int) error {
+	if n < 0 {
+		return errors.New("negative")
+	}
+	return nil
+}
+
+var h Handler = check
+
+func main() {
+	h2 := Handler(func(n int) error {
+		return nil
+	})
+	var l Logf = fmt.Printf
+	l("%d %d\n", 1, 2)
+	fmt.Println(h(1), h2(-1))
+}
+`)
+}
+
+func TestMapUserTypes(t *testing.T) {
+	gopClTest(t, `
+type Point struct {
+	X, Y int
+}
+
+type Key struct {
+	A string
+	B int
+}
+
+type T struct {
+	m map[*T]int
+}
+
+m := map[string]*Point{"a": &Point{1, 2}}
+n := map[Key]Point{Key{"a", 1}: Point{3, 4}}
+println m["a"].X, n[Key{"a", 1}].Y
+`, `package main
+
+import fmt "fmt"
+
+type Point struct {
+	X int
+	Y int
+}
+type Key struct {
+	A string
+	B int
+}
+type T struct {
+	m map[*T]int
+}
+
+func main() {
+	m := map[string]*Point{"a": &Point{1, 2}}
+	n := map[Key]Point{Key{"a", 1}: Point{3, 4}}
+	fmt.Println(m["a"].X, n[Key{"a", 1}].Y)
+}
+`)
+}
+
+func TestComparableMapKeys(t *testing.T) {
+	gopClTest(t, `
+var m map[interface{}]bool
+var n map[[2]*int]bool
+var c map[chan int]bool
+`, `package main
+
+var m map[interface {
+}]bool
+var n map[[2]*int]bool
+var c map[chan int]bool
+`)
+}
+
+func TestStringIndexRange(t *testing.T) {
+	gopClTest(t, `
+s := "héllo, 世界"
+var b byte = s[1]
+for i, r := range s {
+	var x rune = r
+	var off int = i
+	println off, x, string(r)
+}
+println b, len(s)
+`, `package main
+
+import fmt "fmt"
+
+func main() {
+	s := "héllo, 世界"
+	var b byte = s[1]
+	for i, r := range s {
+		var x rune = r
+		var off int = i
+		fmt.Println(off, x, string(r))
+	}
+	fmt.Println(b, len(s))
+}
+`)
+}
+
+func TestStringSliceConv(t *testing.T) {
+	gopClTest(t, `
+s := "héllo, 世界"
+rs := []rune(s)
+bs := []byte(s)
+s1 := string(rs)
+s2 := string(bs)
+s3 := string([]rune{'世', '界'})
+s4 := string([]byte{104, 105})
+var p []byte = []byte(nil)
+println len(rs), len(bs), s1 == s, s2 == s, s3, s4, p
+`, `package main
+
+import fmt "fmt"
+
+func main() {
+	s := "héllo, 世界"
+	rs := []rune(s)
+	bs := []byte(s)
+	s1 := string(rs)
+	s2 := string(bs)
+	s3 := string([]rune{'世', '界'})
+	s4 := string([]byte{104, 105})
+	var p []byte = []byte(nil)
+	fmt.Println(len(rs), len(bs), s1 == s, s2 == s, s3, s4, p)
+}
+`)
+}
+
+func TestDefinedNumericTypeMethods(t *testing.T) {
+	gopClTest(t, `
+type Celsius float64
+
+func (c Celsius) String() string {
+	return sprintf("%.1f°C", float64(c))
+}
+
+func (c Celsius) Add(d Celsius) Celsius {
+	return c + d
+}
+
+c := Celsius(20.5)
+d := c*2 + 1
+var f float64 = float64(d)
+println c.String(), d.Add(c), f
+`, `package main
+
+import fmt "fmt"
+
+type Celsius float64
+
+func (c Celsius) String() string {
+	return fmt.Sprintf("%.1f°C", float64(c))
+}
+func (c Celsius) Add(d Celsius) Celsius {
+	return c + d
+}
+func main() {
+	c := Celsius(20.5)
+	d := c*2 + 1
+	var f float64 = float64(d)
+	fmt.Println(c.String(), d.Add(c), f)
+}
+`)
+}
+
+func TestErrorInterface(t *testing.T) {
+	gopClTest(t, `
+type MyErr struct {
+	msg string
+}
+
+func (e *MyErr) Error() string {
+	return e.msg
+}
+
+func f(n int) error {
+	if n < 0 {
+		return &MyErr{"neg"}
+	}
+	return nil
+}
+
+var err error = &MyErr{"x"}
+if e := f(-1); e != nil {
+	println e.Error(), err
+}
+`, `package main
+
+import fmt "fmt"
+
+type MyErr struct {
+	msg string
+}
+
+func (e *MyErr) Error() string {
+	return e.msg
+}
+func f(n int) error {
+	if n < 0 {
+		return &MyErr{"neg"}
+	}
+	return nil
+}
+
+var err error = &MyErr{"x"}
+
+func main() {
+	if e := f(-1); e != nil {
+		fmt.Println(e.Error(), err)
+	}
+}
+`)
+}
+
+func TestPredeclaredIdents(t *testing.T) {
+	gopClTest(t, `
+const (
+	a = iota
+	b
+)
+
+func f() bool {
+	true := false
+	return true
+}
+
+t := true
+var x bool = false
+println a, b, f(), t, x, true
+`, `package main
+
+import fmt "fmt"
+
+const (
+	a = iota
+	b
+)
+
+func f() bool {
+	true := false
+	return true
+}
+func main() {
+	t := true
+	var x bool = false
+	fmt.Println(a, b, f(), t, x, true)
+}
+`)
+}
+
+func TestRangeChan(t *testing.T) {
+	gopClTest(t, `
+ch := make(chan int, 2)
+ch <- 1
+ch <- 2
+close(ch)
+for v := range ch {
+	println v
+}
+for range ch {
+}
+var r <-chan int = ch
+for v <- r {
+	println v
+}
+`, `package main
+
+import fmt "fmt"
+
+func main() {
+	ch := make(chan int, 2)
+	ch <- 1
+	ch <- 2
+	close(ch)
+	for v := range ch {
+		fmt.Println(v)
+	}
+	for range ch {
+	}
+	var r <-chan int = ch
+	for v := range r {
+		fmt.Println(v)
+	}
+}
+`)
+}
+
+func TestCompiledPkgs(t *testing.T) {
+	conf := *baseConf.Ensure()
+	foo, err := cl.CompileSource("example.com/foo", map[string]string{"foo.gop": `package foo
+
+type Point struct {
+	X, Y int
+}
+
+var Origin = Point{}
+
+func Add(a, b int) int {
+	return a + b
+}
+`}, &conf)
+	if err != nil {
+		t.Fatal("CompileSource foo:", err)
+	}
+	conf.CompiledPkgs = map[string]*gox.Package{"example.com/foo": foo}
+	gopClTestFiles(t, &conf, "", map[string]string{"a.gop": `
+import "example.com/foo"
+
+var p foo.Point = foo.Origin
+println foo.Add(p.X, 1)
+`}, `package main
+
+import (
+	fmt "fmt"
+	foo "example.com/foo"
+)
+
+var p foo.Point = foo.Origin
+
+func main() {
+	fmt.Println(foo.Add(p.X, 1))
+}
+`)
+}
+
+func TestCompiledPkgsUnexported(t *testing.T) {
+	conf := *baseConf.Ensure()
+	foo, err := cl.CompileSource("example.com/foo", map[string]string{"foo.gop": `package foo
+
+type Point struct{}
+
+type point struct{}
+
+var hidden = 1
+
+func Exported() int {
+	return helper()
+}
+
+func helper() int {
+	return 1
+}
+`}, &conf)
+	if err != nil {
+		t.Fatal("CompileSource foo:", err)
+	}
+	conf.CompiledPkgs = map[string]*gox.Package{"example.com/foo": foo}
+	_, err = cl.CompileSource("", map[string]string{"a.gop": `
+import "example.com/foo"
+
+var p foo.Point
+println foo.Exported()
+`}, &conf)
+	if err != nil {
+		t.Fatal("CompileSource:", err)
+	}
+	for src, msg := range map[string]string{
+		"func main() {\n\tprintln(foo.hidden)\n}": "a.gop:5:10: cannot refer to unexported name foo.hidden",
+		"func main() {\n\tfoo.helper()\n}":        "a.gop:5:2: cannot refer to unexported name foo.helper",
+		"func main() {\n\tfoo.hidden = 3\n}":      "a.gop:5:2: cannot refer to unexported name foo.hidden",
+		"var p foo.point":                         "a.gop:4:7: cannot refer to unexported name foo.point",
+	} {
+		_, err = cl.CompileSource("", map[string]string{
+			"a.gop": "\nimport \"example.com/foo\"\n\n" + src + "\n",
+		}, &conf)
+		if err == nil || err.Error() != msg {
+			t.Fatal("CompileSource:", err)
+		}
+	}
+}
+
+func TestCompiledPkgsImports(t *testing.T) {
+	conf := *baseConf.Ensure()
+	foo, err := cl.CompileSource("example.com/foo", map[string]string{"foo.gop": `package foo
+
+import "strings"
+
+func New() *strings.Builder {
+	return new(strings.Builder)
+}
+`}, &conf)
+	if err != nil {
+		t.Fatal("CompileSource foo:", err)
+	}
+	other := *baseConf
+	other.PkgsLoader = nil // load strings again
+	other.CompiledPkgs = map[string]*gox.Package{"example.com/foo": foo}
+	gopClTestFiles(t, other.Ensure(), "", map[string]string{"a.gop": `
+import (
+	"example.com/foo"
+	"strings"
+)
+
+var b *strings.Builder = foo.New()
+`}, `package main
+
+import (
+	strings "strings"
+	foo "example.com/foo"
+)
+
+var b *strings.Builder = foo.New()
+`)
+}
+
+func TestVarZeroValues(t *testing.T) {
+	gopClTest(t, `
+type Inner struct {
+	N int
+	S string
+}
+
+type Outer struct {
+	In  Inner
+	Arr [2]Inner
+	P   *Inner
+}
+
+var (
+	i   int
+	f   float64
+	s   string
+	b   bool
+	p   *int
+	sl  []int
+	m   map[string]int
+	ch  chan int
+	fn  func()
+	e   error
+	any interface{}
+	st  Outer
+	arr [3]int
+	c   complex128
+	r   rune
+)
+
+func main() {
+	var o Outer
+	println i, f, s, b, p, sl, m, ch, fn == nil, e, any, st, arr, c, r, o.In.N, o.Arr[1].S, o.P
+}
+`, `package main
+
+import fmt "fmt"
+
+type Inner struct {
+	N int
+	S string
+}
+type Outer struct {
+	In  Inner
+	Arr [2]Inner
+	P   *Inner
+}
+
+var i int
+var f float64
+var s string
+var b bool
+var p *int
+var sl []int
+var m map[string]int
+var ch chan int
+var fn func()
+var e error
+var any interface {
+}
+var st Outer
+var arr [3]int
+var c complex128
+var r rune
+
+func main() {
+	var o Outer
+	fmt.Println(i, f, s, b, p, sl, m, ch, fn == nil, e, any, st, arr, c, r, o.In.N, o.Arr[1].S, o.P)
+}
+`)
+}
+
+func TestParenTypesAndExprs(t *testing.T) {
+	gopClTest(t, `
+import "unsafe"
+
+type T struct {
+	X int
+}
+
+var a, b, c = 1, 2, 3
+x := (int)(3.0)
+y := ((float64))(x)
+z := (a + b) * c
+t := &T{X: 1}
+ptr := unsafe.Pointer(t)
+q := (*T)(ptr)
+var arr [((2))]int
+var ps []((*T))
+var m map[(string)]([]int)
+var fn func((int)) (string)
+println x, y, z, q.X, arr, ps, m, fn, (((a))) + ((b))
+`, `package main
+
+import (
+	fmt "fmt"
+	unsafe "unsafe"
+)
+
+type T struct {
+	X int
+}
+
+var a, b, c = 1, 2, 3
+
+func main() {
+	x := int(3.0)
+	y := float64(x)
+	z := (a + b) * c
+	t := &T{X: 1}
+	ptr := unsafe.Pointer(t)
+	q := (*T)(ptr)
+	var arr [2]int
+	var ps []*T
+	var m map[string][]int
+	var fn func(int) string
+	fmt.Println(x, y, z, q.X, arr, ps, m, fn, a+b)
+}
+`)
+}
@@ -20,6 +20,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/goplus/gop/ast"
 	"github.com/goplus/gop/cl"
 	"github.com/goplus/gop/parser"
 	"github.com/goplus/gop/parser/parsertest"
@@ -183,6 +184,13 @@ import "os"
 
 func foo() {
 	os.UndefinedObject
+}`)
+	codeErrorTest(t,
+		"./bar.gop:5:2: undefined: fmt.Printlnx", `
+import "fmt"
+
+func foo() {
+	fmt.Printlnx("Hi")
 }`)
 	codeErrorTest(t,
 		"./bar.gop:2:13: undefined: testing", `
@@ -194,6 +202,26 @@ import "testing"
 
 func foo(t testing.Verbose) {
 }`)
+	codeErrorTest(t,
+		"./bar.gop:3:2: could not import nonexist/pkg", `
+import (
+	"nonexist/pkg"
+	"bytes"
+)
+
+var b bytes.Buffer
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:2: could not import nonexist/pkg", `
+import (
+	"nonexist/pkg"
+	"strings"
+)
+
+func main() {
+	println strings.ToUpper("a")
+}
+`)
 }
 
 func TestErrConst(t *testing.T) {
@@ -208,6 +236,15 @@ const (
 	a = iota
 	b, c
 )
+`)
+	codeErrorTest(t,
+		"./bar.gop:2:16: constant 1000 overflows int8", `
+const a int8 = 1000
+`)
+	codeErrorTest(t,
+		"./bar.gop:2:19: constant -1 overflows uint\n"+
+			"./bar.gop:2:23: constant 18446744073709551616 overflows uint", `
+const a, b uint = -1, 1 << 64
 `)
 }
 
@@ -216,6 +253,108 @@ func TestErrNewVar(t *testing.T) {
 		"./bar.gop:3:5: a redeclared in this block\n\tprevious declaration at ./bar.gop:2:5", `
 var a int
 var a string
+`)
+	codeErrorTest(t,
+		"./bar.gop:2:14: constant 300 overflows int8", `
+var a int8 = 300
+`)
+	codeErrorTest(t,
+		"./bar.gop:2:21: constant -1 overflows uint", `func foo() {
+	var a, b uint = 1, -1
+}
+`)
+}
+
+func TestErrConstOverflow(t *testing.T) {
+	codeErrorTest(t,
+		"./bar.gop:10:12: constant 300 overflows int8\n"+
+			"./bar.gop:15:6: constant 300 overflows int8\n"+
+			"./bar.gop:16:4: constant 300 overflows int8\n"+
+			"./bar.gop:17:10: constant 256 overflows uint8\n"+
+			"./bar.gop:19:11: constant -129 overflows int8\n"+
+			"./bar.gop:20:16: constant 200 overflows int8\n"+
+			"./bar.gop:21:20: constant 256 overflows uint8\n"+
+			"./bar.gop:22:21: constant 300 overflows int8\n"+
+			"./bar.gop:22:32: constant -1 overflows uint8\n"+
+			"./bar.gop:23:11: constant 300 overflows uint8\n"+
+			"./bar.gop:24:11: constant 200 overflows int8\n"+
+			"./bar.gop:26:8: constant 256 overflows uint8", `
+func f(a int8, b ...uint8) {}
+
+type T struct {
+	A int8
+	B uint8
+}
+
+func g() (int, int8) {
+	return 1, 300
+}
+
+func main() {
+	var x int8
+	x = 300
+	f(300)
+	f(1, 2, 256)
+	var arr [2]int8
+	arr[0] = -129
+	_ = []int8{1, 200}
+	_ = [...]uint8{2: 256}
+	_ = map[int8]uint8{300: 1, 1: -1}
+	_ = T{1, 300}
+	_ = T{A: 200}
+	var p *T
+	p.B = 256
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:4:14: constant 300 overflows int8\n"+
+			"./bar.gop:12:10: constant 300 overflows T\n"+
+			"./bar.gop:12:17: constant 128 overflows int8\n"+
+			"./bar.gop:14:8: constant 300 overflows int8", `
+const a int8 = 100
+
+var b int8 = a * 3
+
+type T uint8
+
+const c T = 200
+
+func main() {
+	const a int8 = -128
+	println(c+100, -a)
+	ch := make(chan int8, 1)
+	ch <- 300
+	ch <- 3
+}
+`)
+}
+
+func TestErrNewType(t *testing.T) {
+	codeErrorTest(t,
+		"./bar.gop:3:6: A redeclared in this block\n\tprevious declaration at ./bar.gop:2:6", `
+type A int
+type A string
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:6: a redeclared in this block\n\tprevious declaration at ./bar.gop:2:5", `
+var a int
+type a string
+`)
+	codeErrorTest(t,
+		"./bar.gop:2:6: invalid recursive type T", `
+type T struct {
+	t T
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:6:6: invalid recursive type B", `
+type A struct {
+	b B
+}
+
+type B struct {
+	a [2]A
+}
 `)
 }
 
@@ -224,6 +363,12 @@ func TestErrDefineVar(t *testing.T) {
 		"./bar.gop:3:6: cannot use \"Hi\" (type untyped string) as type int in assignment", `
 a := 1
 a := "Hi"
+`)
+	codeErrorTest(t, "./bar.gop:2:7: use of untyped nil in assignment\n"+
+		"./bar.gop:3:10: use of untyped nil in variable declaration", `func foo() {
+	a := nil
+	var b = nil
+}
 `)
 }
 
@@ -336,6 +481,13 @@ type a *int
 
 func (p a) foo() {
 }
+`)
+	codeErrorTest(t,
+		`./bar.gop:4:9: invalid receiver type int (int is not a defined type)`, `
+type MyInt = int
+
+func (p MyInt) foo() {
+}
 `)
 	codeErrorTest(t,
 		`./bar.gop:2:9: invalid receiver type error (error is an interface type)`, `
@@ -351,6 +503,59 @@ func (p []byte) foo() {
 		`./bar.gop:2:10: invalid receiver type []byte ([]byte is not a defined type)`, `
 func (p *[]byte) foo() {
 }
+`)
+	codeErrorTest(t,
+		`./bar.gop:2:10: undefined: T (receiver type of M, N)`, `
+func (t *T) M() {
+}
+
+func (t T) N() int {
+	return 1
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:4:7: T is not a type`, `
+func T() {}
+
+func (T) M() {}
+`)
+	codeErrorTest(t,
+		`./bar.gop:4:8: T is not a type`, `
+var T int
+
+func (*T) M() {}
+`)
+	codeErrorTest(t,
+		"./bar.gop:2:7: T is not a type\n"+
+			"./bar.gop:4:7: T is not a type", `
+func (T) M() {}
+
+func (T) N() {}
+
+func T() {}
+`)
+	codeErrorTest(t,
+		`./bar.gop:6:13: method T.M already declared at ./bar.gop:3:12`, `
+type T struct{}
+func (t T) M() {
+}
+
+func (t *T) M() {
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:4:6: method has multiple receivers`, `
+type T struct{}
+
+func (a, b T) M() {
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:4:6: method has no receiver`, `
+type T struct{}
+
+func () M() {
+}
 `)
 }
 
@@ -369,6 +574,26 @@ a := struct{x int; y string}{1}
 		`./bar.gop:3:33: cannot use x (type int) as type string in value of field y`, `
 x := 1
 a := struct{x int; y string}{1, x}
+`)
+	codeErrorTest(t,
+		`./bar.gop:7:13: unknown field Z in struct literal of type Point`, `
+type Point struct {
+	X, Y int
+}
+
+func foo() {
+	p := Point{Z: 1}
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:7:19: mixture of field:value and value elements in struct literal`, `
+type Point struct {
+	X, Y int
+}
+
+func foo() {
+	p := Point{X: 1, 2}
+}
 `)
 }
 
@@ -377,6 +602,26 @@ func TestErrArray(t *testing.T) {
 		`./bar.gop:3:8: non-constant array bound n`, `
 var n int
 var a [n]int
+`)
+	codeErrorTest(t,
+		`./bar.gop:2:9: constant 2.5 truncated to integer`, `func foo() {
+	var a [2.5]int
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:3:8: array length n (constant 2 of type float64) must be integer`, `
+const n float64 = 2
+var a [n]int
+`)
+	codeErrorTest(t,
+		`./bar.gop:2:9: array bound -1 must be non-negative`, `func foo() {
+	var a [-1]int
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:2:14: cannot use 1.5 (type untyped float) as type int in assignment`, `func foo() {
+	var x int = 1.5
+}
 `)
 }
 
@@ -437,6 +682,12 @@ b := []int{a}
 		`
 a := "Hi"
 b := []int{2: a}
+`)
+	codeErrorTest(t,
+		`./bar.gop:2:22: duplicate index 1 in array or slice literal`,
+		`func foo() {
+	a := []int{1: 1, 2, 1: 3}
+}
 `)
 }
 
@@ -447,6 +698,31 @@ func TestErrMapLit(t *testing.T) {
 		`
 a := map[string]int{1+2: 2}
 b := map[string]int{"Hi": "Go" + "+"}
+`)
+	codeErrorTest(t,
+		`./bar.gop:2:30: duplicate key "a" in map literal`,
+		`func foo() {
+	a := map[string]int{"a": 1, "a": 2}
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:2:29: duplicate key 1.0 in map literal`,
+		`func foo() {
+	a := map[float64]int{1: 1, 1.0: 2}
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:2:29: duplicate key 1e0 in map literal`,
+		`func foo() {
+	a := map[float64]int{1: 1, 1e0: 2}
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:3:35: duplicate key int32(97) in map literal`,
+		`func foo() {
+	a := map[interface{}]int{1: 1, int64(1): 2, "a": 3, 1.0: 4}
+	b := map[interface{}]int{'a': 1, int32(97): 2}
+}
 `)
 }
 
@@ -572,9 +848,997 @@ break foo`)
 }
 
 func TestErrBranchStmt(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:2:2: goto L jumps over variable declaration at line 3`,
+		`func foo() {
+	goto L
+	x := 1
+L:
+	println x
+}`)
+	codeErrorTest(t,
+		`./bar.gop:3:3: goto L jumps over variable declaration at line 5`,
+		`func foo() {
+	if true {
+		goto L
+	}
+	var x int
+L:
+	println x
+}`)
 	codeErrorTest(t,
 		`./bar.gop:2:2: fallthrough statement out of place`,
 		`func foo() {
 	fallthrough
 }`)
+	codeErrorTest(t,
+		`./bar.gop:6:3: cannot fallthrough final case in switch`,
+		`func foo(x int) {
+	switch x {
+	case 1:
+		println "one"
+	default:
+		fallthrough
+	}
+}`)
+	codeErrorTest(t,
+		`./bar.gop:4:3: fallthrough statement out of place`,
+		`func foo(x interface{}) {
+	switch x.(type) {
+	case int:
+		fallthrough
+	default:
+	}
+}`)
+	codeErrorTest(t,
+		`./bar.gop:5:13: invalid continue label L`,
+		`func foo() {
+L:
+	if true {
+		for {
+			continue L
+		}
+	}
+}`)
+	codeErrorTest(t,
+		`./bar.gop:5:10: invalid break label L`,
+		`func foo() {
+L:
+	{
+		for {
+			break L
+		}
+	}
+}`)
+}
+
+func TestErrReturnShadowed(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:6:3: result parameter n not in scope at return`, `
+func foo() (n int) {
+	if true {
+		n := 2
+		_ = n
+		return
+	}
+	return
+}
+`)
+}
+
+func TestErrAmbiguousSelector(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:16:2: ambiguous selector c.Foo`, `
+type A struct{}
+
+func (A) Foo() {}
+
+type B struct{}
+
+func (B) Foo() {}
+
+type C struct {
+	A
+	B
+}
+
+func foo(c C) {
+	c.Foo()
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:16:2: ambiguous selector c.X`, `
+type A struct {
+	X int
+}
+
+type B struct {
+	X int
+}
+
+type C struct {
+	A
+	B
+}
+
+func foo(c C) {
+	c.X = 1
+}
+`)
+}
+
+func TestErrSelectStmt(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:4:7: select case must be receive, send or assign recv`, `
+func foo(ch chan int) {
+	select {
+	case x := 1:
+		println(x)
+	}
+}
+`)
+}
+
+func TestErrInterfaceAssign(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:12:16: cannot use T(2) (type T) as type Named in assignment`, `
+type T int
+
+func (T) Name() string { return "t" }
+
+type Named interface {
+	Name() string
+	Age() int
+}
+
+func foo() {
+	var n Named = T(2)
+}
+`)
+}
+
+func TestErrConversion(t *testing.T) {
+	codeErrorTest(t,
+		"./bar.gop:2:11: constant 3.9 truncated to integer", `func foo() {
+	a := int(3.9)
+}
+`)
+}
+
+func TestErrAppend(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:3:16: cannot use "x" (type untyped string) as type int in append`, `func foo() {
+	var s []int
+	s = append(s, "x")
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:3:16: cannot use []string{"a"} (type []string) as type []int in append`, `func foo() {
+	var s []int
+	s = append(s, []string{"a"}...)
+}
+`)
+}
+
+func TestErrMake(t *testing.T) {
+	codeErrorTest(t,
+		"./bar.gop:2:7: make(map[string]int, 1, 2) expects 1 or 2 arguments; found 3", `func foo() {
+	m := make(map[string]int, 1, 2)
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:2:7: make([]int) expects 2 or 3 arguments; found 1", `func foo() {
+	s := make([]int)
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:2:12: cannot make int; type must be slice, map, or channel", `func foo() {
+	s := make(int, 1)
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:2:19: size argument "a" (type untyped string) must be integer`, `func foo() {
+	s := make([]int, "a")
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:2:19: size argument -1 must not be negative", `func foo() {
+	s := make([]int, -1)
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:2:19: len larger than cap in make([]int)", `func foo() {
+	s := make([]int, 10, 5)
+}
+`)
+}
+
+func TestErrLenCap(t *testing.T) {
+	codeErrorTest(t,
+		"./bar.gop:2:11: invalid argument 1 (type untyped int) for len", `func foo() {
+	n := len(1)
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:11: invalid argument m (type map[string]int) for cap", `func foo() {
+	var m map[string]int
+	n := cap(m)
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:11: invalid argument q (type *[]int) for len", `func foo() {
+	var q *[]int
+	n := len(q)
+}
+`)
+}
+
+func TestErrSliceExpr(t *testing.T) {
+	codeErrorTest(t,
+		"./bar.gop:3:7: invalid operation s[1:2:3] (3-index slice of string)", `func foo() {
+	s := "hello"
+	t := s[1:2:3]
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:11: invalid slice indices: 1 < 2", `func foo() {
+	s := []int{1, 2, 3}
+	t := s[2:1]
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:13: invalid slice indices: 2 < 3", `func foo() {
+	s := []int{1, 2, 3}
+	t := s[0:3:2]
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:11: invalid slice index 4 (out of bounds for 3-element array)", `func foo() {
+	a := [3]int{1, 2, 3}
+	t := a[1:4]
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:9: invalid slice index -1 (index must be non-negative)", `func foo() {
+	s := []int{1, 2, 3}
+	t := s[-1:]
+}
+`)
+}
+
+func TestErrAddrOf(t *testing.T) {
+	codeErrorTest(t,
+		"./bar.gop:2:7: cannot take the address of 1", `func foo() {
+	p := &1
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:6:7: cannot take the address of bar()", `func bar() int {
+	return 1
+}
+
+func foo() {
+	p := &bar()
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:3:7: cannot take the address of m["a"]`, `func foo() {
+	m := map[string]int{"a": 1}
+	p := &m["a"]
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:7: cannot take the address of s[0]", `func foo() {
+	s := "abc"
+	p := &s[0]
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:4:7: cannot take the address of fmt.Println", `import "fmt"
+
+func foo() {
+	p := &fmt.Println
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:9:7: cannot take the address of t.M", `type T struct {
+	X int
+}
+
+func (t T) M() {}
+
+func foo() {
+	var t T
+	p := &t.M
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:10:7: cannot take the address of get().X", `type T struct {
+	X int
+}
+
+func get() T {
+	return T{}
+}
+
+func foo() {
+	p := &get().X
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:6:7: cannot take the address of T{}.X", `type T struct {
+	X int
+}
+
+func foo() {
+	p := &T{}.X
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:4:7: cannot take the address of f", `func f() {}
+
+func foo() {
+	p := &f
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:8: invalid indirect of x (type int)", `func foo() {
+	x := 1
+	y := *x
+}
+`)
+}
+
+func TestErrPointerField(t *testing.T) {
+	codeErrorTest(t,
+		"./bar.gop:7:10: p.Z undefined (type *Point has no field or method Z)", `type Point struct {
+	X, Y int
+}
+
+func foo() {
+	p := &Point{1, 2}
+	println p.Z
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:7:2: p.Z undefined (type *Point has no field or method Z)", `type Point struct {
+	X, Y int
+}
+
+func foo() {
+	p := &Point{1, 2}
+	p.Z = 1
+}
+`)
+}
+
+func TestErrTypeSwitch(t *testing.T) {
+	codeErrorTest(t,
+		"./bar.gop:2:14: cannot type switch on non-interface value x (type int)", `func foo(x int) {
+	switch v := x.(type) {
+	case int:
+		println v
+	}
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:12: duplicate case int in type switch\n\tprevious case at ./bar.gop:3:7", `func foo(x interface{}) {
+	switch v := x.(type) {
+	case int, int:
+	}
+}
+`)
+}
+
+func TestErrSendRecv(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:3:8: cannot use "x" (type untyped string) as type int in send`, `func foo() {
+	ch := make(chan int, 1)
+	ch <- "x"
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:17: cannot use <-ch (type int) as type string in assignment", `func foo() {
+	ch := make(chan int, 1)
+	var s string = <-ch
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:2: invalid operation: x <- 1 (send to non-chan type int)", `func foo() {
+	x := 1
+	x <- 1
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:7: invalid operation: <-x (receive from non-chan type int)", `func foo() {
+	x := 1
+	v := <-x
+}
+`)
+}
+
+func TestErrClose(t *testing.T) {
+	codeErrorTest(t,
+		"./bar.gop:2:8: invalid operation: cannot close receive-only channel c (type <-chan int)", `func foo(c <-chan int) {
+	close(c)
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:8: invalid operation: non-chan argument x (type int) for close", `func foo() {
+	x := 1
+	close(x)
+}
+`)
+}
+
+func TestErrChanDirection(t *testing.T) {
+	codeErrorTest(t,
+		"./bar.gop:2:2: invalid operation: c <- 1 (send to receive-only type <-chan int)", `func foo(c <-chan int) {
+	c <- 1
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:2:7: invalid operation: <-c (receive from send-only type chan<- int)", `func foo(c chan<- int) {
+	v := <-c
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:2:19: cannot use c (type <-chan int) as type chan int in assignment", `func foo(c <-chan int) {
+	var d chan int = c
+}
+`)
+}
+
+func TestErrIntegerOp(t *testing.T) {
+	codeErrorTest(t,
+		"./bar.gop:4:7: invalid operation: a + b (mismatched types int and int64)", `func foo() {
+	var a int = 1
+	var b int64 = 2
+	c := a + b
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:7: invalid operation: a << 2 (shift of type float64)", `func foo() {
+	a := 1.5
+	b := a << 2
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:4:7: invalid operation: a << s (shift count type string, must be integer)", `func foo() {
+	a := 1
+	s := "x"
+	b := a << s
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:7: invalid operation: a % 2 (operator % not defined on float64)", `func foo() {
+	a := 1.5
+	b := a % 2
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:2:7: invalid operation: 7 % 2.0 (operator % not defined on untyped float)", `func foo() {
+	a := 7 % 2.0
+}
+`)
+}
+
+func TestErrComparison(t *testing.T) {
+	codeErrorTest(t,
+		"./bar.gop:3:7: invalid operation: s == t (slice can only be compared to nil)", `func foo() {
+	var s, t []int
+	b := s == t
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:7: invalid operation: m == n (map can only be compared to nil)", `func foo() {
+	var m, n map[string]int
+	b := m == n
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:7: invalid operation: p < q (operator < not defined on struct)", `func foo() {
+	var p, q struct{ A int }
+	b := p < q
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:7: invalid operation: s < nil (operator < not defined on nil)", `func foo() {
+	var s []int
+	b := s < nil
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:7:7: invalid operation: p == q (T cannot be compared)", `type T struct {
+	A []int
+}
+
+func foo() {
+	var p, q T
+	b := p == q
+}
+`)
+}
+
+func TestErrLogicalOp(t *testing.T) {
+	codeErrorTest(t,
+		"./bar.gop:3:7: invalid operation: x && y (operator && not defined on int)", `func foo() {
+	x, y := 1, 2
+	a := x && y
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:7: invalid operation: a || 2 (operator || not defined on untyped int)", `func foo() {
+	a := true
+	b := a || 2
+}
+`)
+}
+
+func TestErrIncDec(t *testing.T) {
+	codeErrorTest(t,
+		"./bar.gop:3:2: invalid operation: s++ (non-numeric type string)", `func foo() {
+	s := "x"
+	s++
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:2:2: cannot assign to 1", `func foo() {
+	1++
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:2: cannot assign to c (declared const)", `func foo() {
+	const c = 1
+	c++
+}
+`)
+}
+
+func TestErrAssignOp(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:3:2: invalid operation: s -= "y" (operator - not defined on string)`, `func foo() {
+	s := "x"
+	s -= "y"
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:2: invalid operation: f %= 2 (operator % not defined on float64)", `func foo() {
+	f := 1.5
+	f %= 2
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:3:2: invalid operation: a += "x" (mismatched types int and untyped string)`, `func foo() {
+	a := 1
+	a += "x"
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:2:7: invalid operation: "a" - "b" (operator - not defined on untyped string)`, `func foo() {
+	a := "a" - "b"
+}
+`)
+	codeErrorTest(t,
+		"./bar.gop:3:7: invalid operation: b + b (operator + not defined on bool)", `func foo() {
+	var b bool
+	c := b + b
+}
+`)
+}
+
+func TestErrMultiValueCall(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:9:16: cannot use int value as type string in argument to g(f())`, `
+func f() (int, string) {
+	return 1, "a"
+}
+
+func g(a string, b int) {
+}
+
+g(f())
+`)
+	codeErrorTest(t,
+		`./bar.gop:9:16: multiple-value f() (type (int, string)) in single-value context`, `
+func f() (int, string) {
+	return 1, "a"
+}
+
+func g(a int, b string, c int) {
+}
+
+g(f(), 1)
+`)
+	codeErrorTest(t,
+		`./bar.gop:7:13: multiple-value f() (type (int, string)) in single-value context`, `
+func f() (int, string) {
+	return 1, "a"
+}
+
+func main() {
+	println(1, f())
+}
+`)
+}
+
+func TestErrConstStringConcat(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:2:11: invalid operation: "x" + 1 (mismatched types untyped string and untyped int)`, `
+const a = "x" + 1
+`)
+	codeErrorTest(t,
+		`./bar.gop:3:11: invalid operation: "x" + n (mismatched types untyped string and untyped int)`, `
+const n = 2
+const b = "x" + n
+`)
+}
+
+func TestErrCopy(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:5:2: arguments to copy b (type []string) and a (type []int) have different element types`, `
+func main() {
+	a := []int{1, 2, 3}
+	b := make([]string, 2)
+	copy(b, a)
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:4:2: copy expects slice arguments; found 1 (type untyped int) and a (type []int)`, `
+func main() {
+	a := []int{1, 2, 3}
+	copy(1, a)
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:4:2: arguments to copy a (type []int) and "abc" (type untyped string) have different element types`, `
+func main() {
+	a := []int{1, 2, 3}
+	copy(a, "abc")
+}
+`)
+}
+
+func TestErrDelete(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:4:9: first argument to delete must be map; have a (type []int)`, `
+func main() {
+	a := []int{1}
+	delete(a, 0)
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:4:12: cannot use 1 (type untyped int) as type string in argument to delete`, `
+func main() {
+	m := map[string]int{"a": 1}
+	delete(m, 1)
+}
+`)
+}
+
+func TestErrNew(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:3:11: 1 is not a type`, `
+func main() {
+	p := new(1)
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:3:7: new(int, 2) expects 1 argument; found 2`, `
+func main() {
+	p := new(int, 2)
+}
+`)
+}
+
+func TestErrConstForwardRef(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:4:7: undefined: B`, `
+func main() {
+	const (
+		A = B + 1
+		B = 1
+	)
+}
+`)
+}
+
+func TestErrAnonymousStruct(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:5:6: cannot use q (type struct{Y int}) as type struct{X int} in assignment`, `
+func main() {
+	var p struct{ X int }
+	q := struct{ Y int }{Y: 1}
+	p = q
+}
+`)
+}
+
+func TestErrFuncType(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:6:6: cannot use func literal (type func(s string) error) as type Handler in assignment`, `
+type Handler func(int) error
+
+func main() {
+	var h Handler
+	h = func(s string) error {
+		return nil
+	}
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:4:14: use of builtin printf not in function call`, `
+type Logf func(format string, args ...interface{})
+
+var l Logf = printf
+`)
+}
+
+func TestErrMapKeyType(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:2:11: invalid map key type []int`, `
+var m map[[]int]string
+`)
+	codeErrorTest(t,
+		`./bar.gop:2:11: invalid map key type map[string]int`, `
+var m map[map[string]int]bool
+`)
+	codeErrorTest(t,
+		`./bar.gop:2:11: invalid map key type func()`, `
+var m map[func()]bool
+`)
+	codeErrorTest(t,
+		`./bar.gop:2:11: invalid map key type [2][]int`, `
+var m map[[2][]int]bool
+`)
+	codeErrorTest(t,
+		`./bar.gop:2:12: invalid map key type K`, `
+type M map[K]int
+
+type K []int
+`)
+	codeErrorTest(t,
+		`./bar.gop:3:8: invalid map key type T`, `
+type T struct {
+	m map[T]int
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:10:16: invalid map key type K`, `
+type K struct {
+	a int
+	b struct {
+		f func()
+	}
+}
+
+func main() {
+	m := make(map[K]int)
+}
+`)
+}
+
+func TestErrStringIndex(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:4:15: cannot use s[1] (type byte) as type rune in assignment`, `
+func main() {
+	s := "héllo"
+	var r rune = s[1]
+}
+`)
+}
+
+func TestErrConvertible(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:3:13: cannot convert 1 (type untyped int) to type []int`, `
+func main() {
+	s := []int(1)
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:4:13: cannot convert s (type string) to type []int`, `
+func main() {
+	s := "abc"
+	x := []int(s)
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:4:14: cannot convert x (type []int) to type string`, `
+func main() {
+	x := []int{1}
+	s := string(x)
+}
+`)
+}
+
+func TestErrDefinedNumericType(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:6:18: cannot use f (type float64) as type Celsius in assignment`, `
+type Celsius float64
+
+func main() {
+	var f float64 = 1
+	var c Celsius = f
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:6:18: cannot use c (type Celsius) as type float64 in assignment`, `
+type Celsius float64
+
+func main() {
+	var c Celsius = 1
+	var f float64 = c
+}
+`)
+}
+
+func TestErrErrorInterface(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:5:18: cannot use T{} (type T) as type error in assignment`, `
+type T struct{}
+
+func main() {
+	var err error = T{}
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:9:18: cannot use T{} (type T) as type error in assignment`, `
+type T struct{}
+
+func (T) Error() int {
+	return 1
+}
+
+func main() {
+	var err error = T{}
+}
+`)
+}
+
+func TestErrIota(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:3:7: cannot use iota outside constant declaration`, `
+func main() {
+	x := iota
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:2:9: cannot use iota outside constant declaration`, `
+var x = iota
+`)
+}
+
+func TestErrRangeChan(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:4:20: range over ch permits only one iteration variable`, `
+func main() {
+	ch := make(chan int)
+	for i, v := range ch {
+	}
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:4:14: range over ch permits only one iteration variable`, `
+func main() {
+	ch := make(chan int)
+	for i, v <- ch {
+	}
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:4:17: invalid operation: range ch (receive from send-only type chan<- int)`, `
+func main() {
+	ch := make(chan<- int)
+	for v := range ch {
+	}
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:3:17: undefined: x`, `
+func main() {
+	for v := range x {
+	}
+}
+`)
+}
+
+func TestErrMultiFilesImport(t *testing.T) {
+	fs := parsertest.NewMemFS(map[string][]string{
+		"/foo": {"a.gop", "b.gop"},
+	}, map[string]string{
+		"/foo/a.gop": "import s \"strings\"\n\nfunc upper(v string) string {\n\treturn s.ToUpper(v)\n}\n",
+		"/foo/b.gop": "func lower(v string) string {\n\treturn s.ToLower(v)\n}\n",
+	})
+	pkgs, err := parser.ParseFSDir(gblFset, fs, "/foo", nil, 0)
+	if err != nil {
+		t.Fatal("parser.ParseFSDir failed:", err)
+	}
+	conf := *baseConf.Ensure()
+	conf.WorkingDir = "/foo"
+	_, err = cl.NewPackage("", pkgs["main"], &conf)
+	if err == nil || err.Error() != "./b.gop:2:9: undefined: s" {
+		t.Fatal("TestErrMultiFilesImport:", err)
+	}
+}
+
+func TestErrImportSelf(t *testing.T) {
+	fs := parsertest.NewSingleFileFS("/foo", "bar.gop", `package foo
+
+import "github.com/goplus/gop/foo"
+`)
+	pkgs, err := parser.ParseFSDir(gblFset, fs, "/foo", nil, 0)
+	if err != nil {
+		t.Fatal("parser.ParseFSDir failed:", err)
+	}
+	conf := *baseConf.Ensure()
+	conf.WorkingDir = "/foo"
+	_, err = cl.NewPackage("github.com/goplus/gop/foo", pkgs["foo"], &conf)
+	if err == nil || err.Error() !=
+		"./bar.gop:3:8: import cycle not allowed: github.com/goplus/gop/foo imports itself" {
+		t.Fatal("TestErrImportSelf:", err)
+	}
+}
+
+func TestErrUnknownDecl(t *testing.T) {
+	fs := parsertest.NewSingleFileFS("/foo", "bar.gop", "package main\n\nvar a int\n")
+	pkgs, err := parser.ParseFSDir(gblFset, fs, "/foo", nil, 0)
+	if err != nil {
+		t.Fatal("parser.ParseFSDir failed:", err)
+	}
+	bar := pkgs["main"]
+	f := bar.Files["/foo/bar.gop"]
+	f.Decls = append(f.Decls, &ast.BadDecl{From: f.Decls[0].Pos(), To: f.Decls[0].End()})
+	conf := *baseConf.Ensure()
+	conf.WorkingDir = "/foo"
+	_, err = cl.NewPackage("", bar, &conf)
+	if err == nil || err.Error() != "./bar.gop:3:1: unknown declaration *ast.BadDecl" {
+		t.Fatal("TestErrUnknownDecl:", err)
+	}
+}
+
+func TestErrInvalidSyntax(t *testing.T) {
+	codeErrorTest(t,
+		`./bar.gop:4:2: assignment operation += requires single-valued expressions`, `
+func main() {
+	a, b := 1, 2
+	a, b += 1, 2
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:3:8: missing key in map literal`, `
+func main() {
+	x := {1, 2}
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:3:7: invalid composite literal type int`, `
+func main() {
+	x := int{1}
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:4:7: use of .(type) outside type switch`, `
+func main() {
+	var x interface{}
+	y := x.(type)
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:4:9: can't use expr? in global`, `
+import "strconv"
+
+var x = strconv.Itoa(1)?
+`)
+	codeErrorTest(t,
+		`./bar.gop:3:8: invalid use of [...] array (outside a composite literal)`, `
+func main() {
+	x := [...]
+}
+`)
+	codeErrorTest(t,
+		`./bar.gop:3:2: missing label in goto statement`, `
+func main() {
+	goto
+}
+`)
 }
@@ -24,6 +24,7 @@ import (
 	"strings"
 
 	goast "go/ast"
+	"go/constant"
 	gotoken "go/token"
 	"go/types"
 
@@ -105,6 +106,7 @@ func compileIdent(ctx *blockCtx, ident *ast.Ident, flags int) *gox.PkgRef {
 	// pkgRef object
 	if (flags & clIdentSelectorExpr) != 0 {
 		if pkgRef, ok := ctx.imports[name]; ok {
+			delete(ctx.unused, name)
 			return pkgRef
 		}
 	}
@@ -119,6 +121,9 @@ func compileIdent(ctx *blockCtx, ident *ast.Ident, flags int) *gox.PkgRef {
 		panic(ctx.newCodeErrorf(ident.Pos(), "use of builtin %s not in function call", name))
 	}
 	if obj := ctx.pkg.Builtin().TryRef(name); obj != nil {
+		if (flags&clIdentAllowBuiltin) == 0 && isOverloadFunc(obj) {
+			panic(ctx.newCodeErrorf(ident.Pos(), "use of builtin %s not in function call", name))
+		}
 		o = obj
 	} else if o == nil {
 		if (clIdentGoto & flags) != 0 {
@@ -126,12 +131,20 @@ func compileIdent(ctx *blockCtx, ident *ast.Ident, flags int) *gox.PkgRef {
 			panic(ctx.newCodeErrorf(l.Pos(), "label %v is not defined", l.Name))
 		}
 		panic(ctx.newCodeErrorf(ident.Pos(), "undefined: %s", name))
+	} else if o == types.Universe.Lookup("iota") && !ctx.inConst {
+		panic(ctx.newCodeError(ident.Pos(), "cannot use iota outside constant declaration"))
 	}
 
 find:
 	if fvalue {
+		if ctx.used != nil {
+			ctx.used[o] = true
+		}
 		ctx.cb.Val(o, ident)
 	} else {
+		if _, ok := o.(*types.Const); ok {
+			panic(ctx.newCodeErrorf(ident.Pos(), "cannot assign to %s (declared const)", name))
+		}
 		ctx.cb.VarRef(o, ident)
 	}
 	return nil
@@ -144,8 +157,21 @@ func isBuiltin(o types.Object) bool {
 	return false
 }
 
+// isOverloadFunc reports whether o is an overloaded Go+ builtin like println,
+// which is a type name whose type isn't a named type like bigint.
+func isOverloadFunc(o types.Object) bool {
+	if t, ok := o.(*types.TypeName); ok {
+		_, named := t.Type().(*types.Named)
+		return !named
+	}
+	return false
+}
+
 func compileMember(ctx *blockCtx, v ast.Node, name string, flags int) error {
 	cb := ctx.cb
+	if err := checkAmbiguousSelector(ctx, v, name); err != nil {
+		return err
+	}
 	lhs := (flags & clIdentLHS) != 0
 	kind, err := cb.Member(name, lhs, v)
 	if kind != 0 {
@@ -166,18 +192,38 @@ func compileMember(ctx *blockCtx, v ast.Node, name string, flags int) error {
 	return err
 }
 
-func compileExprLHS(ctx *blockCtx, expr ast.Expr) {
+// checkAmbiguousSelector returns an error if name is promoted from embedded
+// fields at the same depth of the type on the top of the code stack.
+func checkAmbiguousSelector(ctx *blockCtx, v ast.Node, name string) error {
+	typ := ctx.cb.Get(-1).Type
+	if o, index, _ := types.LookupFieldOrMethod(typ, true, ctx.pkg.Types, name); o == nil && index != nil {
+		src, _ := ctx.LoadExpr(v)
+		return ctx.newCodeErrorf(v.Pos(), "ambiguous selector %s", src)
+	}
+	return nil
+}
+
+// compileExprLHS pushes a reference to the variable expr and returns the type
+// of the variable, or nil if it is unknown.
+func compileExprLHS(ctx *blockCtx, expr ast.Expr) types.Type {
 	switch v := expr.(type) {
 	case *ast.Ident:
 		compileIdent(ctx, v, clIdentLHS)
+		if o, ok := lookupIdent(ctx, v).(*types.Var); ok && v.Name != "_" {
+			return o.Type()
+		}
+		return nil
 	case *ast.IndexExpr:
-		compileIndexExprLHS(ctx, v)
+		return compileIndexExprLHS(ctx, v)
 	case *ast.SelectorExpr:
-		compileSelectorExprLHS(ctx, v)
+		return compileSelectorExprLHS(ctx, v)
 	case *ast.StarExpr:
-		compileStarExprLHS(ctx, v)
+		return compileStarExprLHS(ctx, v)
+	case *ast.ParenExpr:
+		return compileExprLHS(ctx, v.X)
 	default:
-		log.Panicln("compileExpr failed: unknown -", reflect.TypeOf(v))
+		src, _ := ctx.LoadExpr(v)
+		panic(ctx.newCodeErrorf(v.Pos(), "cannot assign to %s", src))
 	}
 }
 
@@ -199,6 +245,7 @@ func compileExpr(ctx *blockCtx, expr ast.Expr, twoValue ...bool) {
 		compileFuncLit(ctx, v)
 	case *ast.CompositeLit:
 		compileCompositeLit(ctx, v, nil, false)
+		ctx.cb.Get(-1).Src = v
 	case *ast.SliceLit:
 		compileSliceLit(ctx, v)
 	case *ast.IndexExpr:
@@ -228,7 +275,7 @@ func compileExpr(ctx *blockCtx, expr ast.Expr, twoValue ...bool) {
 	case *ast.FuncType:
 		ctx.cb.Typ(toFuncType(ctx, v, nil), v)
 	case *ast.Ellipsis:
-		panic("compileEllipsis: ast.Ellipsis unexpected")
+		panic(ctx.newCodeError(v.Pos(), "invalid use of [...] array (outside a composite literal)"))
 	case *ast.KeyValueExpr:
 		panic("compileExpr: ast.KeyValueExpr unexpected")
 	default:
@@ -245,25 +292,311 @@ func compileExprOrNone(ctx *blockCtx, expr ast.Expr) {
 }
 
 func compileUnaryExpr(ctx *blockCtx, v *ast.UnaryExpr, twoValue bool) {
-	compileExpr(ctx, v.X)
+	if v.Op == token.AND {
+		if !compileAddressable(ctx, v.X) && !isCompositeLit(v.X) || ctx.cb.Get(-1).CVal != nil {
+			src, _ := ctx.LoadExpr(v.X)
+			panic(ctx.newCodeErrorf(v.Pos(), "cannot take the address of %s", src))
+		}
+	} else {
+		compileExpr(ctx, v.X)
+	}
+	if v.Op == token.ARROW {
+		typ := ctx.cb.Get(-1).Type
+		if t, ok := typ.Underlying().(*types.Chan); !ok {
+			src, _ := ctx.LoadExpr(v)
+			panic(ctx.newCodeErrorf(v.Pos(), "invalid operation: %s (receive from non-chan type %v)", src, typ))
+		} else if t.Dir() == types.SendOnly {
+			src, _ := ctx.LoadExpr(v)
+			panic(ctx.newCodeErrorf(v.Pos(), "invalid operation: %s (receive from send-only type %v)", src, typ))
+		}
+	}
 	ctx.cb.UnaryOp(gotoken.Token(v.Op), twoValue)
+	ctx.cb.Get(-1).Src = v
+	checkTypedOverflow(ctx, ctx.cb.Get(-1), v)
+}
+
+// compileAddressable compiles x and reports whether it is addressable: a
+// variable, a pointer indirection, a field of an addressable struct or of a
+// struct pointer, or an element of a slice or of an addressable array.
+func compileAddressable(ctx *blockCtx, x ast.Expr) bool {
+	switch v := x.(type) {
+	case *ast.Ident:
+		compileExpr(ctx, v)
+		_, ok := lookupIdent(ctx, v).(*types.Var)
+		return ok
+	case *ast.StarExpr:
+		compileExpr(ctx, v)
+		return true
+	case *ast.ParenExpr:
+		return compileAddressable(ctx, v.X)
+	case *ast.IndexExpr:
+		addressable := compileAddressable(ctx, v.X)
+		switch t := ctx.cb.Get(-1).Type.Underlying().(type) {
+		case *types.Slice:
+			addressable = true
+		case *types.Pointer:
+			_, addressable = t.Elem().Underlying().(*types.Array)
+		case *types.Array:
+		default: // map, string
+			addressable = false
+		}
+		compileExpr(ctx, v.Index)
+		ctx.cb.Index(1, false, v)
+		return addressable
+	case *ast.SelectorExpr:
+		addressable := true
+		if x, ok := v.X.(*ast.Ident); ok {
+			if at := compileIdent(ctx, x, clIdentAutoCall|clIdentSelectorExpr); at != nil {
+				o, _ := lookupPkgRef(ctx, at, v.Sel)
+				compilePkgMember(ctx, at, v, clIdentAutoCall)
+				_, isVar := o.(*types.Var)
+				return isVar
+			}
+		} else {
+			addressable = compileAddressable(ctx, v.X)
+		}
+		o, indirect := lookupMember(ctx, ctx.cb.Get(-1).Type, v.Sel.Name)
+		if err := compileMember(ctx, v, v.Sel.Name, clIdentAutoCall); err != nil {
+			panic(err)
+		}
+		_, isField := o.(*types.Var)
+		return isField && (addressable || indirect)
+	}
+	compileExpr(ctx, x)
+	return false
+}
+
+// lookupMember looks up the field or method name of typ. As in selectors, a
+// lowercase name also finds the member whose name is capitalized.
+func lookupMember(ctx *blockCtx, typ types.Type, name string) (o types.Object, indirect bool) {
+	o, _, indirect = types.LookupFieldOrMethod(typ, true, ctx.pkg.Types, name)
+	if c := name[0]; o == nil && c >= 'a' && c <= 'z' {
+		name = string(rune(c)+('A'-'a')) + name[1:]
+		o, _, indirect = types.LookupFieldOrMethod(typ, true, ctx.pkg.Types, name)
+	}
+	return
+}
+
+// lookupIdent returns the object ident refers to without marking it used.
+func lookupIdent(ctx *blockCtx, ident *ast.Ident) types.Object {
+	if _, o := ctx.cb.Scope().LookupParent(ident.Name, token.NoPos); o != nil {
+		return o
+	}
+	o, _ := lookupPkgRef(ctx, nil, ident)
+	return o
 }
 
 func compileBinaryExpr(ctx *blockCtx, v *ast.BinaryExpr) {
 	compileExpr(ctx, v.X)
 	compileExpr(ctx, v.Y)
+	checkBinaryOp(ctx, v)
 	ctx.cb.BinaryOp(gotoken.Token(v.Op), v)
+	checkTypedOverflow(ctx, ctx.cb.Get(-1), v)
 }
 
-func compileIndexExprLHS(ctx *blockCtx, v *ast.IndexExpr) {
+// checkBinaryOp checks the operands of v against the operator. The operands
+// must be on the top of the code stack.
+func checkBinaryOp(ctx *blockCtx, v *ast.BinaryExpr) {
+	checkArithOp(ctx, v)
+	checkIntegerOp(ctx, v)
+	checkComparison(ctx, v)
+	checkLogicalOp(ctx, v)
+}
+
+// checkLogicalOp reports non-boolean operands of && and ||. The operands must
+// be on the top of the code stack.
+func checkLogicalOp(ctx *blockCtx, v *ast.BinaryExpr) {
+	if v.Op != token.LAND && v.Op != token.LOR {
+		return
+	}
+	for i := -2; i < 0; i++ {
+		typ := ctx.cb.Get(i).Type
+		if o, _, _ := types.LookupFieldOrMethod(typ, true, ctx.pkg.Types, binaryGopNames[v.Op.String()]); o != nil {
+			return
+		}
+		if t, ok := typ.Underlying().(*types.Basic); !ok || (t.Info()&types.IsBoolean) == 0 {
+			src, _ := ctx.LoadExpr(v)
+			panic(ctx.newCodeErrorf(v.Pos(), "invalid operation: %s (operator %v not defined on %v)", src, v.Op, typ))
+		}
+	}
+}
+
+// checkComparison reports comparisons of operands whose type doesn't support
+// the comparison operator and doesn't overload it either. The operands must be
+// on the top of the code stack.
+func checkComparison(ctx *blockCtx, v *ast.BinaryExpr) {
+	var ordered bool
+	switch v.Op {
+	case token.EQL, token.NEQ:
+	case token.LSS, token.LEQ, token.GTR, token.GEQ:
+		ordered = true
+	default:
+		return
+	}
+	x, y := ctx.cb.Get(-2), ctx.cb.Get(-1)
+	if isUntypedNil(x.Type) || isUntypedNil(y.Type) {
+		if ordered {
+			src, _ := ctx.LoadExpr(v)
+			panic(ctx.newCodeErrorf(v.Pos(), "invalid operation: %s (operator %v not defined on nil)", src, v.Op))
+		}
+		return
+	}
+	if !types.Identical(x.Type, y.Type) {
+		return // mismatched or untyped operands are left to gox
+	}
+	typ := x.Type
+	if o, _, _ := types.LookupFieldOrMethod(typ, true, ctx.pkg.Types, binaryGopNames[v.Op.String()]); o != nil {
+		return
+	}
+	if ordered {
+		if t, ok := typ.Underlying().(*types.Basic); !ok || (t.Info()&types.IsOrdered) == 0 {
+			src, _ := ctx.LoadExpr(v)
+			panic(ctx.newCodeErrorf(v.Pos(), "invalid operation: %s (operator %v not defined on %s)", src, v.Op, kindOf(typ)))
+		}
+		return
+	}
+	if !types.Comparable(typ) {
+		src, _ := ctx.LoadExpr(v)
+		switch typ.Underlying().(type) {
+		case *types.Slice, *types.Map, *types.Signature:
+			panic(ctx.newCodeErrorf(v.Pos(), "invalid operation: %s (%s can only be compared to nil)", src, kindOf(typ)))
+		}
+		panic(ctx.newCodeErrorf(v.Pos(), "invalid operation: %s (%v cannot be compared)", src, typ))
+	}
+}
+
+func isUntypedNil(typ types.Type) bool {
+	t, ok := typ.(*types.Basic)
+	return ok && t.Kind() == types.UntypedNil
+}
+
+// kindOf returns the name of the kind of typ as used in error messages.
+func kindOf(typ types.Type) string {
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		return t.Name()
+	case *types.Slice:
+		return "slice"
+	case *types.Map:
+		return "map"
+	case *types.Signature:
+		return "func"
+	case *types.Struct:
+		return "struct"
+	case *types.Pointer:
+		return "pointer"
+	case *types.Chan:
+		return "chan"
+	case *types.Interface:
+		return "interface"
+	case *types.Array:
+		return "array"
+	}
+	return typ.String()
+}
+
+// checkIntegerOp reports non-integer operands of the operators that are only
+// defined on integers: %, &, |, ^, &^, << and >>. Operands of other than basic
+// types are left to operator overloading. The operands must be on the top of
+// the code stack.
+func checkIntegerOp(ctx *blockCtx, v *ast.BinaryExpr) {
+	switch v.Op {
+	case token.REM, token.AND, token.OR, token.XOR, token.AND_NOT, token.SHL, token.SHR:
+	default:
+		return
+	}
+	x, y := ctx.cb.Get(-2), ctx.cb.Get(-1)
+	tx, ok1 := x.Type.Underlying().(*types.Basic)
+	ty, ok2 := y.Type.Underlying().(*types.Basic)
+	if !ok1 || !ok2 {
+		return
+	}
+	if v.Op == token.SHL || v.Op == token.SHR {
+		if !isIntegral(tx, x.CVal) {
+			src, _ := ctx.LoadExpr(v)
+			panic(ctx.newCodeErrorf(v.Pos(), "invalid operation: %s (shift of type %v)", src, x.Type))
+		}
+		if !isIntegral(ty, y.CVal) {
+			src, _ := ctx.LoadExpr(v)
+			panic(ctx.newCodeErrorf(v.Pos(), "invalid operation: %s (shift count type %v, must be integer)", src, y.Type))
+		}
+		if (ty.Info() & types.IsInteger) == 0 { // untyped constant count, e.g. x << 2.0
+			y.Type, y.CVal = types.Typ[types.UntypedInt], constant.ToInt(y.CVal)
+		}
+		return
+	}
+	if t := binaryOpType(tx, ty); (t.Info() & types.IsInteger) == 0 {
+		src, _ := ctx.LoadExpr(v)
+		panic(ctx.newCodeErrorf(v.Pos(), "invalid operation: %s (operator %v not defined on %v)", src, v.Op, t))
+	}
+}
+
+// checkArithOp reports operands of +, -, * and / that are neither numbers nor,
+// for +, strings. Operands of other than basic types are left to operator
+// overloading. The operands must be on the top of the code stack.
+func checkArithOp(ctx *blockCtx, v *ast.BinaryExpr) {
+	switch v.Op {
+	case token.ADD, token.SUB, token.MUL, token.QUO:
+	default:
+		return
+	}
+	tx, ok1 := ctx.cb.Get(-2).Type.Underlying().(*types.Basic)
+	ty, ok2 := ctx.cb.Get(-1).Type.Underlying().(*types.Basic)
+	if !ok1 || !ok2 {
+		return
+	}
+	info := binaryOpType(tx, ty).Info()
+	if (info&types.IsNumeric) == 0 && (v.Op != token.ADD || (info&types.IsString) == 0) {
+		src, _ := ctx.LoadExpr(v)
+		panic(ctx.newCodeErrorf(v.Pos(), "invalid operation: %s (operator %v not defined on %v)", src, v.Op, binaryOpType(tx, ty)))
+	}
+}
+
+// binaryOpType returns the type the operands of a binary operation are
+// converted to: the typed one of them, or the larger untyped kind.
+func binaryOpType(tx, ty *types.Basic) *types.Basic {
+	if (tx.Info()&types.IsUntyped) != 0 && ((ty.Info()&types.IsUntyped) == 0 || ty.Kind() > tx.Kind()) {
+		return ty
+	}
+	return tx
+}
+
+// isIntegral reports whether a value of type t is an integer, or an untyped
+// numeric constant with an integer value.
+func isIntegral(t *types.Basic, cval constant.Value) bool {
+	if (t.Info() & types.IsInteger) != 0 {
+		return true
+	}
+	return cval != nil && (t.Info()&types.IsUntyped) != 0 && (t.Info()&types.IsNumeric) != 0 &&
+		constant.ToInt(cval).Kind() == constant.Int
+}
+
+func compileIndexExprLHS(ctx *blockCtx, v *ast.IndexExpr) (typ types.Type) {
 	compileExpr(ctx, v.X)
+	t := ctx.cb.Get(-1).Type.Underlying()
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem().Underlying()
+	}
+	switch t := t.(type) {
+	case *types.Slice:
+		typ = t.Elem()
+	case *types.Array:
+		typ = t.Elem()
+	case *types.Map:
+		typ = t.Elem()
+	}
 	compileExpr(ctx, v.Index)
 	ctx.cb.IndexRef(1, v)
+	return
 }
 
-func compileStarExprLHS(ctx *blockCtx, v *ast.StarExpr) { // *x = ...
+func compileStarExprLHS(ctx *blockCtx, v *ast.StarExpr) (typ types.Type) { // *x = ...
 	compileExpr(ctx, v.X)
+	if t, ok := ctx.cb.Get(-1).Type.Underlying().(*types.Pointer); ok {
+		typ = t.Elem()
+	}
 	ctx.cb.ElemRef()
+	return
 }
 
 func compileStarExpr(ctx *blockCtx, v *ast.StarExpr) { // ... = *x
@@ -274,7 +607,7 @@ func compileStarExpr(ctx *blockCtx, v *ast.StarExpr) { // ... = *x
 func compileTypeAssertExpr(ctx *blockCtx, v *ast.TypeAssertExpr, twoValue bool) {
 	compileExpr(ctx, v.X)
 	if v.Type == nil {
-		panic("TODO: x.(type) is only used in type switch")
+		panic(ctx.newCodeError(v.Pos(), "use of .(type) outside type switch"))
 	}
 	typ := toType(ctx, v.Type)
 	ctx.cb.TypeAssert(typ, twoValue, v)
@@ -293,33 +626,84 @@ func compileSliceExpr(ctx *blockCtx, v *ast.SliceExpr) { // x[i:j:k]
 	if v.Slice3 {
 		compileExprOrNone(ctx, v.Max)
 	}
+	checkSliceIndices(ctx, v)
 	ctx.cb.Slice(v.Slice3, v)
 }
 
-func compileSelectorExprLHS(ctx *blockCtx, v *ast.SelectorExpr) {
+// checkSliceIndices reports constant indices of x[i:j:k] that are negative,
+// out of bounds for an array or not in increasing order. The operands must be
+// on the top of the code stack.
+func checkSliceIndices(ctx *blockCtx, v *ast.SliceExpr) {
+	idx := []ast.Expr{v.Low, v.High}
+	if v.Slice3 {
+		idx = append(idx, v.Max)
+	}
+	n := len(idx)
+	length := int64(-1)
+	t := ctx.cb.Get(-n - 1).Type.Underlying()
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem().Underlying()
+	}
+	if a, ok := t.(*types.Array); ok {
+		length = a.Len()
+	}
+	prev := int64(-1)
+	for i, x := range idx {
+		if x == nil {
+			continue
+		}
+		cv := ctx.cb.Get(i - n).CVal
+		if cv == nil {
+			continue
+		}
+		val, ok := constant.Int64Val(constant.ToInt(cv))
+		if !ok {
+			continue
+		}
+		if val < 0 {
+			panic(ctx.newCodeErrorf(x.Pos(), "invalid slice index %v (index must be non-negative)", cv))
+		}
+		if length >= 0 && val > length {
+			panic(ctx.newCodeErrorf(x.Pos(), "invalid slice index %v (out of bounds for %d-element array)", cv, length))
+		}
+		if prev > val {
+			panic(ctx.newCodeErrorf(x.Pos(), "invalid slice indices: %d < %d", val, prev))
+		}
+		prev = val
+	}
+}
+
+func compileSelectorExprLHS(ctx *blockCtx, v *ast.SelectorExpr) types.Type {
 	switch x := v.X.(type) {
 	case *ast.Ident:
 		if at := compileIdent(ctx, x, clIdentLHS|clIdentSelectorExpr); at != nil {
-			ctx.cb.VarRef(at.Ref(v.Sel.Name))
-			return
+			compilePkgMember(ctx, at, v, clIdentLHS)
+			o, _ := lookupPkgRef(ctx, at, v.Sel)
+			if o, ok := o.(*types.Var); ok {
+				return o.Type()
+			}
+			return nil
 		}
 	default:
 		compileExpr(ctx, v.X)
 	}
+	if err := checkAmbiguousSelector(ctx, v, v.Sel.Name); err != nil {
+		panic(err)
+	}
+	o, _ := lookupMember(ctx, ctx.cb.Get(-1).Type, v.Sel.Name)
 	ctx.cb.MemberRef(v.Sel.Name, v)
+	if o, ok := o.(*types.Var); ok {
+		return o.Type()
+	}
+	return nil
 }
 
 func compileSelectorExpr(ctx *blockCtx, v *ast.SelectorExpr, flags int) {
 	switch x := v.X.(type) {
 	case *ast.Ident:
 		if at := compileIdent(ctx, x, flags|clIdentSelectorExpr); at != nil {
-			if compilePkgRef(ctx, at, v.Sel, flags) {
-				return
-			}
-			if token.IsExported(v.Sel.Name) {
-				panic(ctx.newCodeErrorf(x.Pos(), "undefined: %s.%s", x.Name, v.Sel.Name))
-			}
-			panic(ctx.newCodeErrorf(x.Pos(), "cannot refer to unexported name %s.%s", x.Name, v.Sel.Name))
+			compilePkgMember(ctx, at, v, flags)
+			return
 		}
 	default:
 		compileExpr(ctx, v.X)
@@ -329,6 +713,28 @@ func compileSelectorExpr(ctx *blockCtx, v *ast.SelectorExpr, flags int) {
 	}
 }
 
+func isCompositeLit(x ast.Expr) bool {
+	switch v := x.(type) {
+	case *ast.CompositeLit:
+		return true
+	case *ast.ParenExpr:
+		return isCompositeLit(v.X)
+	}
+	return false
+}
+
+// compilePkgMember compiles v, a member of the imported package at.
+func compilePkgMember(ctx *blockCtx, at *gox.PkgRef, v *ast.SelectorExpr, flags int) {
+	if compilePkgRef(ctx, at, v.Sel, flags) {
+		return
+	}
+	x := v.X.(*ast.Ident)
+	if token.IsExported(v.Sel.Name) {
+		panic(ctx.newCodeErrorf(x.Pos(), "undefined: %s.%s", x.Name, v.Sel.Name))
+	}
+	panic(ctx.newCodeErrorf(x.Pos(), "cannot refer to unexported name %s.%s", x.Name, v.Sel.Name))
+}
+
 func pkgRef(at *gox.PkgRef, name string) (o types.Object, canAutoCall bool) {
 	if c := name[0]; c >= 'a' && c <= 'z' {
 		name = string(rune(c)+('A'-'a')) + name[1:]
@@ -351,6 +757,9 @@ func lookupPkgRef(ctx *blockCtx, pkg *gox.PkgRef, x *ast.Ident) (o types.Object,
 			pkg, o, canAutoCall = at, o2, canAutoCall2
 		}
 	}
+	if o != nil {
+		delete(ctx.unusedDots, pkg)
+	}
 	return
 }
 
@@ -451,9 +860,316 @@ func compileCallExpr(ctx *blockCtx, v *ast.CallExpr, flags int) {
 			compileExpr(ctx, arg)
 		}
 	}
+	if len(v.Args) > 1 {
+		checkMultiValueArgs(ctx, v.Args)
+	} else if len(v.Args) == 1 && !ellipsis {
+		checkTupleArg(ctx, fnt, v)
+	}
+	checkArgsOverflow(ctx, fnt, v.Args, ellipsis)
+	if t, ok := fnt.(*gox.TypeType); ok && len(v.Args) == 1 {
+		checkConvertible(ctx, t.Type(), v.Args[0])
+		checkConvTruncated(ctx, t.Type(), v.Args[0])
+	} else if len(v.Args) > 1 && isBuiltinFunc(ctx, fnt, "append") {
+		checkAppendArgs(ctx, v.Args, ellipsis)
+	} else if len(v.Args) > 0 && isBuiltinFunc(ctx, fnt, "make") {
+		checkMakeArgs(ctx, v)
+	} else if isBuiltinFunc(ctx, fnt, "new") {
+		checkNewArgs(ctx, v)
+	} else if len(v.Args) == 2 && isBuiltinFunc(ctx, fnt, "copy") {
+		checkCopyArgs(ctx, v)
+	} else if len(v.Args) == 2 && isBuiltinFunc(ctx, fnt, "delete") {
+		checkDeleteArgs(ctx, v.Args)
+	} else if len(v.Args) == 1 {
+		if isBuiltinFunc(ctx, fnt, "close") {
+			checkCloseArg(ctx, v.Args[0])
+		} else if isBuiltinFunc(ctx, fnt, "len") {
+			checkLenArg(ctx, "len", v.Args[0])
+		} else if isBuiltinFunc(ctx, fnt, "cap") {
+			checkLenArg(ctx, "cap", v.Args[0])
+		}
+	}
 	ctx.cb.CallWith(len(v.Args), ellipsis, v)
 }
 
+// isBuiltinFunc reports whether fnt is the type of the builtin function name.
+// The builtin package doesn't have it if it is restricted by Config.NewBuiltin.
+func isBuiltinFunc(ctx *blockCtx, fnt types.Type, name string) bool {
+	o := ctx.pkg.Builtin().TryRef(name)
+	return o != nil && fnt == o.Type()
+}
+
+// checkArgsOverflow reports untyped constant arguments that don't fit in the
+// integer types of their parameters. The arguments must be on the top of the
+// code stack.
+func checkArgsOverflow(ctx *blockCtx, fnt types.Type, args []ast.Expr, ellipsis bool) {
+	sig, ok := fnt.(*types.Signature)
+	if !ok {
+		return
+	}
+	var fn fnType
+	fn.init(sig)
+	n := len(args)
+	for i, arg := range args {
+		if typ := fn.arg(i, ellipsis); typ != nil {
+			checkConstOverflow(ctx, typ, ctx.cb.Get(i-n), arg)
+		}
+	}
+}
+
+// checkMultiValueArgs reports a multi-value call mixed with other arguments,
+// as in g(f(), x). The arguments must be on the top of the code stack.
+func checkMultiValueArgs(ctx *blockCtx, args []ast.Expr) {
+	n := len(args)
+	for i, arg := range args {
+		if t, ok := ctx.cb.Get(i - n).Type.(*types.Tuple); ok {
+			src, _ := ctx.LoadExpr(arg)
+			panic(ctx.newCodeErrorf(arg.Pos(), "multiple-value %s (type %v) in single-value context", src, t))
+		}
+	}
+}
+
+// checkTupleArg reports results of a multi-value call f() that can't be passed
+// to the parameters of g in g(f()).
+func checkTupleArg(ctx *blockCtx, fnt types.Type, v *ast.CallExpr) {
+	t, ok := ctx.cb.Get(-1).Type.(*types.Tuple)
+	if !ok {
+		return
+	}
+	sig, ok := fnt.(*types.Signature)
+	if !ok || sig.Variadic() || sig.Params().Len() != t.Len() {
+		return // arity errors are left to gox
+	}
+	params := sig.Params()
+	for i, n := 0, t.Len(); i < n; i++ {
+		if !types.AssignableTo(t.At(i).Type(), params.At(i).Type()) {
+			src, _ := ctx.LoadExpr(v)
+			panic(ctx.newCodeErrorf(v.Args[0].Pos(), "cannot use %v value as type %v in argument to %s",
+				t.At(i).Type(), params.At(i).Type(), src))
+		}
+	}
+}
+
+// checkCopyArgs reports arguments of copy that aren't slices, or whose element
+// types differ. copy([]byte, string) is allowed. The arguments must be on the
+// top of the code stack.
+func checkCopyArgs(ctx *blockCtx, v *ast.CallExpr) {
+	dst, src := ctx.cb.Get(-2), ctx.cb.Get(-1)
+	var elem types.Type
+	switch t := src.Type.Underlying().(type) {
+	case *types.Slice:
+		elem = t.Elem()
+	case *types.Basic:
+		if t.Info()&types.IsString != 0 {
+			elem = types.Typ[types.Byte]
+		}
+	}
+	dstSrc, _ := ctx.LoadExpr(v.Args[0])
+	srcSrc, _ := ctx.LoadExpr(v.Args[1])
+	t, ok := dst.Type.Underlying().(*types.Slice)
+	if !ok || elem == nil {
+		panic(ctx.newCodeErrorf(v.Pos(), "copy expects slice arguments; found %s (type %v) and %s (type %v)",
+			dstSrc, dst.Type, srcSrc, src.Type))
+	}
+	if !types.Identical(t.Elem(), elem) {
+		panic(ctx.newCodeErrorf(v.Pos(), "arguments to copy %s (type %v) and %s (type %v) have different element types",
+			dstSrc, dst.Type, srcSrc, src.Type))
+	}
+}
+
+// checkDeleteArgs reports a non-map argument of delete, or a key that can't be
+// used as the key type of the map. The arguments must be on the top of the
+// code stack.
+func checkDeleteArgs(ctx *blockCtx, args []ast.Expr) {
+	m, key := ctx.cb.Get(-2), ctx.cb.Get(-1)
+	t, ok := m.Type.Underlying().(*types.Map)
+	if !ok {
+		src, _ := ctx.LoadExpr(args[0])
+		panic(ctx.newCodeErrorf(args[0].Pos(), "first argument to delete must be map; have %s (type %v)", src, m.Type))
+	}
+	if !gox.AssignableConv(ctx.pkg, key.Type, t.Key(), key) {
+		src, _ := ctx.LoadExpr(args[1])
+		panic(ctx.newCodeErrorf(args[1].Pos(), "cannot use %s (type %v) as type %v in argument to delete", src, key.Type, t.Key()))
+	}
+}
+
+// checkAppendArgs reports elements that can't be appended to the slice passed
+// as the first argument of append. The arguments must be on the top of the
+// code stack.
+func checkAppendArgs(ctx *blockCtx, args []ast.Expr, ellipsis bool) {
+	n := len(args)
+	t, ok := ctx.cb.Get(-n).Type.Underlying().(*types.Slice)
+	if !ok {
+		return
+	}
+	elem := t.Elem()
+	for i := 1; i < n; i++ {
+		e := ctx.cb.Get(i - n)
+		if ellipsis {
+			if _, ok := e.Type.Underlying().(*types.Slice); !ok {
+				return // append([]byte, string...) and errors left to gox
+			}
+			elem = t
+		}
+		if !gox.AssignableConv(ctx.pkg, e.Type, elem, e) {
+			src, _ := ctx.LoadExpr(args[i])
+			panic(ctx.newCodeErrorf(args[i].Pos(), "cannot use %s (type %v) as type %v in append", src, e.Type, elem))
+		}
+	}
+}
+
+// checkNewArgs reports a call of new that doesn't take exactly one type. The
+// arguments must be on the top of the code stack.
+func checkNewArgs(ctx *blockCtx, v *ast.CallExpr) {
+	if n := len(v.Args); n != 1 {
+		src, _ := ctx.LoadExpr(v)
+		panic(ctx.newCodeErrorf(v.Pos(), "%s expects 1 argument; found %d", src, n))
+	}
+	if _, ok := ctx.cb.Get(-1).Type.(*gox.TypeType); !ok {
+		src, _ := ctx.LoadExpr(v.Args[0])
+		panic(ctx.newCodeErrorf(v.Args[0].Pos(), "%s is not a type", src))
+	}
+}
+
+// checkMakeArgs validates the type and size arguments of make. The arguments
+// must be on the top of the code stack.
+func checkMakeArgs(ctx *blockCtx, v *ast.CallExpr) {
+	n := len(v.Args)
+	tt, ok := ctx.cb.Get(-n).Type.(*gox.TypeType)
+	if !ok {
+		src, _ := ctx.LoadExpr(v.Args[0])
+		panic(ctx.newCodeErrorf(v.Args[0].Pos(), "%s is not a type", src))
+	}
+	typ := tt.Type()
+	min := 1
+	switch typ.Underlying().(type) {
+	case *types.Slice:
+		min = 2
+	case *types.Map, *types.Chan:
+	default:
+		panic(ctx.newCodeErrorf(
+			v.Args[0].Pos(), "cannot make %v; type must be slice, map, or channel", typ))
+	}
+	if n < min || n > min+1 {
+		src, _ := ctx.LoadExpr(v)
+		panic(ctx.newCodeErrorf(v.Pos(), "%s expects %d or %d arguments; found %d", src, min, min+1, n))
+	}
+	var sizes []constant.Value
+	for i := 1; i < n; i++ {
+		e := ctx.cb.Get(i - n)
+		arg := v.Args[i]
+		if !isSizeArg(e) {
+			src, _ := ctx.LoadExpr(arg)
+			panic(ctx.newCodeErrorf(arg.Pos(), "size argument %s (type %v) must be integer", src, e.Type))
+		}
+		if e.CVal == nil {
+			continue
+		}
+		x := constant.ToInt(e.CVal)
+		if x.Kind() != constant.Int {
+			panic(ctx.newCodeErrorf(arg.Pos(), "constant %v truncated to integer", e.CVal))
+		}
+		if constant.Sign(x) < 0 {
+			src, _ := ctx.LoadExpr(arg)
+			panic(ctx.newCodeErrorf(arg.Pos(), "size argument %s must not be negative", src))
+		}
+		sizes = append(sizes, x)
+	}
+	if len(sizes) == 2 && constant.Compare(sizes[0], gotoken.GTR, sizes[1]) {
+		panic(ctx.newCodeErrorf(v.Args[1].Pos(), "len larger than cap in make(%v)", typ))
+	}
+}
+
+// checkCloseArg reports arguments of close that are not channels a value can
+// be sent to. The argument must be on the top of the code stack.
+func checkCloseArg(ctx *blockCtx, arg ast.Expr) {
+	typ := ctx.cb.Get(-1).Type
+	t, ok := typ.Underlying().(*types.Chan)
+	if !ok {
+		src, _ := ctx.LoadExpr(arg)
+		panic(ctx.newCodeErrorf(arg.Pos(), "invalid operation: non-chan argument %s (type %v) for close", src, typ))
+	}
+	if t.Dir() == types.RecvOnly {
+		src, _ := ctx.LoadExpr(arg)
+		panic(ctx.newCodeErrorf(arg.Pos(), "invalid operation: cannot close receive-only channel %s (type %v)", src, typ))
+	}
+}
+
+// checkLenArg reports arguments of len and cap that have no length or
+// capacity. The argument must be on the top of the code stack.
+func checkLenArg(ctx *blockCtx, fn string, arg ast.Expr) {
+	typ := ctx.cb.Get(-1).Type
+	t := typ.Underlying()
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem().Underlying()
+		if _, ok = t.(*types.Array); !ok {
+			t = p
+		}
+	}
+	switch t := t.(type) {
+	case *types.Slice, *types.Array, *types.Chan:
+		return
+	case *types.Map:
+		if fn == "len" {
+			return
+		}
+	case *types.Basic:
+		if fn == "len" && (t.Info()&types.IsString) != 0 {
+			return
+		}
+	}
+	src, _ := ctx.LoadExpr(arg)
+	panic(ctx.newCodeErrorf(arg.Pos(), "invalid argument %s (type %v) for %s", src, typ, fn))
+}
+
+// isSizeArg reports whether e is an integer or an untyped numeric constant.
+func isSizeArg(e *gox.Element) bool {
+	t, ok := e.Type.Underlying().(*types.Basic)
+	if !ok {
+		return false
+	}
+	if (t.Info() & types.IsInteger) != 0 {
+		return true
+	}
+	return e.CVal != nil && (t.Info()&types.IsUntyped) != 0 && (t.Info()&types.IsNumeric) != 0
+}
+
+// checkConvertible reports conversions between basic and slice types that Go
+// doesn't allow, e.g. []int("abc"). Other conversions are left to gox, which
+// also handles Go+ types like bigint.
+func checkConvertible(ctx *blockCtx, typ types.Type, arg ast.Expr) {
+	e := ctx.cb.Get(-1)
+	if !isBasicOrSlice(typ) || !isBasicOrSlice(e.Type) || types.ConvertibleTo(e.Type, typ) {
+		return
+	}
+	src, _ := ctx.LoadExpr(arg)
+	panic(ctx.newCodeErrorf(arg.Pos(), "cannot convert %s (type %v) to type %v", src, e.Type, typ))
+}
+
+func isBasicOrSlice(typ types.Type) bool {
+	switch typ.Underlying().(type) {
+	case *types.Basic, *types.Slice:
+		return true
+	}
+	return false
+}
+
+// checkConvTruncated reports conversions of untyped non-integral constants
+// to integer types, e.g. int(3.9).
+func checkConvTruncated(ctx *blockCtx, typ types.Type, arg ast.Expr) {
+	t, ok := typ.Underlying().(*types.Basic)
+	if !ok || (t.Info()&types.IsInteger) == 0 {
+		return
+	}
+	e := ctx.cb.Get(-1)
+	if et, ok := e.Type.(*types.Basic); !ok || (et.Info()&types.IsUntyped) == 0 || e.CVal == nil {
+		return
+	}
+	if x := constant.ToInt(e.CVal); x.Kind() != constant.Int {
+		pos := ctx.Position(arg.Pos())
+		ctx.handleCodeErrorf(&pos, "constant %v truncated to integer", e.CVal)
+	}
+}
+
 func compileLambdaParams(ctx *blockCtx, pos token.Pos, lhs []*ast.Ident, in *types.Tuple) []*types.Var {
 	pkg := ctx.pkg
 	n := len(lhs)
@@ -485,6 +1201,9 @@ func compileLambdaExpr(ctx *blockCtx, v *ast.LambdaExpr, in *types.Tuple) {
 		results[i] = pkg.NewAutoParam("")
 	}
 	ctx.cb.NewClosure(types.NewTuple(params...), types.NewTuple(results...), false).BodyStart(pkg)
+	if ctx.funcScopes != nil { // lambda parameters are never reported as unused
+		ctx.funcScopes[ctx.cb.Scope()] = true
+	}
 	for _, v := range v.Rhs {
 		compileExpr(ctx, v)
 	}
@@ -508,6 +1227,7 @@ func compileFuncLit(ctx *blockCtx, v *ast.FuncLit) {
 	if body := v.Body; body != nil {
 		loadFuncBody(ctx, fn, body)
 		cb.SetComments(comments, false)
+		cb.Get(-1).Src = v
 	}
 }
 
@@ -561,16 +1281,140 @@ func compileCompositeLitElts(ctx *blockCtx, elts []ast.Expr, kind int, expected
 	}
 }
 
+// checkEltsOverflow reports untyped constant elements of a composite literal
+// that don't fit in the integer types of the keys, the elements or the fields.
+// The elements must be on the top of the code stack.
+func checkEltsOverflow(ctx *blockCtx, elts []ast.Expr, kind int, underlying types.Type) {
+	var key, elem types.Type
+	var t *types.Struct
+	switch u := underlying.(type) {
+	case *types.Slice:
+		elem = u.Elem()
+	case *types.Array:
+		elem = u.Elem()
+	case *types.Map:
+		key, elem = u.Key(), u.Elem()
+	case *types.Struct:
+		t = u
+	default:
+		return
+	}
+	n := len(elts)
+	for i, elt := range elts {
+		if kind != compositeLitKeyVal {
+			if t != nil {
+				if i >= t.NumFields() {
+					return // reported by gox
+				}
+				elem = t.Field(i).Type()
+			}
+			checkConstOverflow(ctx, elem, ctx.cb.Get(i-n), elt)
+			continue
+		}
+		val := elt
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key != nil {
+				checkConstOverflow(ctx, key, ctx.cb.Get((i-n)<<1), kv.Key)
+			}
+			val = kv.Value
+		}
+		checkConstOverflow(ctx, elem, ctx.cb.Get((i-n)<<1+1), val)
+	}
+}
+
+// checkDupKeys reports duplicate constant keys of a map literal, or duplicate
+// indices of an array or slice literal. The key-value pairs of elts must be on
+// the top of the code stack.
+func checkDupKeys(ctx *blockCtx, elts []ast.Expr, underlying types.Type) {
+	n := len(elts)
+	switch underlying.(type) {
+	case *types.Slice, *types.Array:
+		seen := make(map[int64]bool, n)
+		idx := int64(-1)
+		for i, elt := range elts {
+			if key := ctx.cb.Get((i - n) << 1); key.CVal != nil {
+				idx, _ = constant.Int64Val(constant.ToInt(key.CVal))
+			} else {
+				idx++
+			}
+			if seen[idx] {
+				pos := ctx.Position(elt.Pos())
+				ctx.handleCodeErrorf(&pos, "duplicate index %d in array or slice literal", idx)
+			}
+			seen[idx] = true
+		}
+	case *types.Map, nil:
+		var kt types.Type
+		if t, ok := underlying.(*types.Map); ok {
+			kt = t.Key()
+		}
+		type constKey struct {
+			typ types.Type // dynamic type of a key of an interface type
+			val constant.Value
+		}
+		keys := make([]constKey, 0, n)
+		for i, elt := range elts {
+			e := ctx.cb.Get((i - n) << 1)
+			if e.CVal == nil {
+				continue
+			}
+			key, typ := constKey{val: e.CVal}, kt
+			if kt != nil && types.IsInterface(kt) {
+				key.typ = types.Default(e.Type)
+				typ = key.typ
+			}
+			if typ != nil {
+				if t, ok := typ.Underlying().(*types.Basic); ok {
+					key.val = constantOf(t, key.val)
+				}
+			}
+			for _, old := range keys {
+				if types.Identical(old.typ, key.typ) && old.val.Kind() == key.val.Kind() &&
+					constant.Compare(old.val, gotoken.EQL, key.val) {
+					kv := elt.(*ast.KeyValueExpr)
+					src, pos := ctx.LoadExpr(kv.Key)
+					ctx.handleCodeErrorf(&pos, "duplicate key %s in map literal", src)
+					break
+				}
+			}
+			keys = append(keys, key)
+		}
+	}
+}
+
+// constantOf converts the constant x to the representation of the basic type
+// t, so that 1 and 1.0 are equal as float64 keys.
+func constantOf(t *types.Basic, x constant.Value) constant.Value {
+	switch info := t.Info(); {
+	case info&types.IsInteger != 0:
+		return constant.ToInt(x)
+	case info&types.IsFloat != 0:
+		return constant.ToFloat(x)
+	case info&types.IsComplex != 0:
+		return constant.ToComplex(x)
+	}
+	return x
+}
+
 func compileStructLitInKeyVal(ctx *blockCtx, elts []ast.Expr, t *types.Struct, typ types.Type) {
 	for _, elt := range elts {
-		kv := elt.(*ast.KeyValueExpr)
-		name := kv.Key.(*ast.Ident).Name
-		if idx := lookupField(t, name); idx >= 0 {
-			ctx.cb.Val(idx)
-		} else {
-			log.Panicln("TODO: struct member not found -", name)
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			panic(ctx.newCodeErrorf(elt.Pos(), "mixture of field:value and value elements in struct literal"))
+		}
+		name, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			src, pos := ctx.LoadExpr(kv.Key)
+			panic(newCodeErrorf(&pos, "invalid field name %s in struct literal", src))
 		}
+		idx := lookupField(t, name.Name)
+		if idx < 0 {
+			panic(ctx.newCodeErrorf(name.Pos(), "unknown field %s in struct literal of type %v",
+				name.Name, types.TypeString(typ, types.RelativeTo(ctx.pkg.Types))))
+		}
+		ctx.cb.Val(idx)
 		compileExpr(ctx, kv.Value)
+		checkConstOverflow(ctx, t.Field(idx).Type(), ctx.cb.Get(-1), kv.Value)
 	}
 	ctx.cb.StructLit(typ, len(elts)<<1, true)
 }
@@ -654,10 +1498,14 @@ func compileCompositeLit(ctx *blockCtx, v *ast.CompositeLit, expected types.Type
 		return
 	}
 	compileCompositeLitElts(ctx, v.Elts, kind, &kvType{underlying: underlying})
+	checkEltsOverflow(ctx, v.Elts, kind, underlying)
+	if kind == compositeLitKeyVal {
+		checkDupKeys(ctx, v.Elts, underlying)
+	}
 	n := len(v.Elts)
 	if typ == nil {
 		if kind == compositeLitVal && n > 0 {
-			panic("TODO: mapLit should be in {key: val, ...} form")
+			panic(ctx.newCodeError(v.Elts[0].Pos(), "missing key in map literal"))
 		}
 		ctx.cb.MapLit(nil, n<<1)
 		return
@@ -672,7 +1520,7 @@ func compileCompositeLit(ctx *blockCtx, v *ast.CompositeLit, expected types.Type
 	case *types.Struct:
 		ctx.cb.StructLit(typ, n, false)
 	default:
-		log.Panicln("compileCompositeLit: unknown type -", reflect.TypeOf(underlying))
+		panic(ctx.newCodeErrorf(v.Pos(), "invalid composite literal type %v", typ))
 	}
 	if hasPtr {
 		ctx.cb.UnaryOp(gotoken.AND)
@@ -802,7 +1650,7 @@ func compileErrWrapExpr(ctx *blockCtx, v *ast.ErrWrapExpr) {
 	pkg, cb := ctx.pkg, ctx.cb
 	useClosure := (v.Tok == token.NOT || v.Default != nil)
 	if !useClosure && (cb.Scope().Parent() == types.Universe) {
-		panic("TODO: can't use expr? in global")
+		panic(ctx.newCodeError(v.Pos(), "can't use expr? in global"))
 	}
 
 	compileExpr(ctx, v.X)
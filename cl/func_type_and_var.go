@@ -20,6 +20,7 @@ import (
 	"go/constant"
 	"go/types"
 	"log"
+	"math"
 	"math/big"
 	"reflect"
 	"strconv"
@@ -46,11 +47,46 @@ func toRecv(ctx *blockCtx, recv *ast.FieldList) *types.Var {
 	return ctx.pkg.NewParam(v.Pos(), name, toType(ctx, v.Type))
 }
 
-func getRecvTypeName(ctx *pkgCtx, recv *ast.FieldList, handleErr bool) (string, bool) {
+// checkRecv reports a method receiver list that doesn't declare exactly one
+// receiver.
+func checkRecv(ctx *pkgCtx, recv *ast.FieldList) bool {
+	switch n := recv.NumFields(); {
+	case n == 0:
+		pos := ctx.Position(recv.Pos())
+		ctx.handleCodeErrorf(&pos, "method has no receiver")
+	case n > 1:
+		pos := ctx.Position(recv.Pos())
+		ctx.handleCodeErrorf(&pos, "method has multiple receivers")
+	default:
+		return true
+	}
+	return false
+}
+
+func findMethodDecl(ld *typeLoader, name string) *ast.FuncDecl {
+	if name == "_" {
+		return nil
+	}
+	for _, d := range ld.mdecls {
+		if d.Name.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// recvType returns the receiver type of a method, without the * of a pointer
+// receiver.
+func recvType(recv *ast.FieldList) ast.Expr {
 	typ := recv.List[0].Type
 	if t, ok := typ.(*ast.StarExpr); ok {
 		typ = t.X
 	}
+	return typ
+}
+
+func getRecvTypeName(ctx *pkgCtx, recv *ast.FieldList, handleErr bool) (string, bool) {
+	typ := recvType(recv)
 	if t, ok := typ.(*ast.Ident); ok {
 		return t.Name, true
 	}
@@ -125,6 +161,8 @@ func toType(ctx *blockCtx, typ ast.Expr) types.Type {
 		return toFuncType(ctx, v, nil)
 	case *ast.SelectorExpr:
 		return toExternalType(ctx, v)
+	case *ast.ParenExpr:
+		return toType(ctx, v.X)
 	}
 	log.Panicln("toType: unknown -", reflect.TypeOf(typ))
 	return nil
@@ -145,6 +183,10 @@ func toChanType(ctx *blockCtx, v *ast.ChanType) *types.Chan {
 func toExternalType(ctx *blockCtx, v *ast.SelectorExpr) types.Type {
 	name := v.X.(*ast.Ident).Name
 	if pkgRef, ok := ctx.imports[name]; ok {
+		delete(ctx.unused, name)
+		if !token.IsExported(v.Sel.Name) {
+			panic(ctx.newCodeErrorf(v.Pos(), "cannot refer to unexported name %s.%s", name, v.Sel.Name))
+		}
 		o := pkgRef.TryRef(v.Sel.Name)
 		if t, ok := o.(*types.TypeName); ok {
 			return t.Type()
@@ -251,10 +293,49 @@ func getTypeName(typ types.Type) string {
 
 func toMapType(ctx *blockCtx, v *ast.MapType) *types.Map {
 	key := toType(ctx, v.Key)
+	if key.Underlying() == nil { // key is a type being declared
+		ctx.tychks = append(ctx.tychks, func() {
+			if key.Underlying() != nil && !types.Comparable(key) {
+				pos := ctx.Position(v.Key.Pos())
+				ctx.handleCodeErrorf(&pos, "invalid map key type %v", key)
+			}
+		})
+	} else if !types.Comparable(key) {
+		pos := ctx.Position(v.Key.Pos())
+		panic(newCodeErrorf(&pos, "invalid map key type %v", key))
+	}
 	val := toType(ctx, v.Value)
 	return types.NewMap(key, val)
 }
 
+// embedsType reports whether typ contains a value of the named type t, that
+// is, not through a pointer, slice, map, channel, function or interface.
+func embedsType(t *types.Named, typ types.Type, seen map[*types.Named]bool) bool {
+	switch v := typ.(type) {
+	case *types.Named:
+		if v == t {
+			return true
+		}
+		if seen[v] || v.Underlying() == nil {
+			return false
+		}
+		if seen == nil {
+			seen = make(map[*types.Named]bool)
+		}
+		seen[v] = true
+		return embedsType(t, v.Underlying(), seen)
+	case *types.Struct:
+		for i, n := 0, v.NumFields(); i < n; i++ {
+			if embedsType(t, v.Field(i).Type(), seen) {
+				return true
+			}
+		}
+	case *types.Array:
+		return embedsType(t, v.Elem(), seen)
+	}
+	return false
+}
+
 func toArrayType(ctx *blockCtx, v *ast.ArrayType) types.Type {
 	elem := toType(ctx, v.Elt)
 	if v.Len == nil {
@@ -263,7 +344,12 @@ func toArrayType(ctx *blockCtx, v *ast.ArrayType) types.Type {
 	if _, ok := v.Len.(*ast.Ellipsis); ok {
 		return types.NewArray(elem, -1) // A negative length indicates an unknown length
 	}
-	return types.NewArray(elem, toInt64(ctx, v.Len, "non-constant array bound %s"))
+	n := toInt64(ctx, v.Len, "non-constant array bound %s")
+	if n < 0 {
+		src, pos := ctx.LoadExpr(v.Len)
+		panic(newCodeErrorf(&pos, "array bound %s must be non-negative", src))
+	}
+	return types.NewArray(elem, n)
 }
 
 func toInt64(ctx *blockCtx, e ast.Expr, emsg string) int64 {
@@ -271,6 +357,10 @@ func toInt64(ctx *blockCtx, e ast.Expr, emsg string) int64 {
 	compileExpr(ctx, e)
 	tv := cb.EndConst()
 	if val := tv.CVal; val != nil {
+		if t, ok := tv.Type.Underlying().(*types.Basic); ok && t.Info()&(types.IsInteger|types.IsUntyped) == 0 {
+			src, pos := ctx.LoadExpr(e)
+			panic(newCodeErrorf(&pos, "array length %s (constant %v of type %v) must be integer", src, val, tv.Type))
+		}
 		if val.Kind() == constant.Float {
 			if v, ok := constant.Val(val).(*big.Rat); ok && v.IsInt() {
 				return v.Num().Int64()
@@ -278,11 +368,96 @@ func toInt64(ctx *blockCtx, e ast.Expr, emsg string) int64 {
 		} else if v, ok := constant.Int64Val(val); ok {
 			return v
 		}
+		if val.Kind() == constant.Float {
+			_, pos := ctx.LoadExpr(e)
+			panic(newCodeErrorf(&pos, "constant %v truncated to integer", val))
+		}
 	}
 	src, pos := ctx.LoadExpr(e)
 	panic(newCodeErrorf(&pos, emsg, src))
 }
 
+// checkIntOverflow reports untyped constants that don't fit in the integer
+// type typ. The values of vals must be on the top of the code stack.
+func checkIntOverflow(ctx *blockCtx, typ types.Type, vals []ast.Expr) {
+	n := len(vals)
+	for i, val := range vals {
+		checkConstOverflow(ctx, typ, ctx.cb.Get(i-n), val)
+	}
+}
+
+// checkConstOverflow reports e, the value of val, if it is an untyped constant
+// that doesn't fit in the integer type typ.
+func checkConstOverflow(ctx *blockCtx, typ types.Type, e *gox.Element, val ast.Expr) {
+	t, ok := typ.Underlying().(*types.Basic)
+	if !ok || (t.Info()&types.IsInteger) == 0 || e.CVal == nil {
+		return
+	}
+	if et, ok := e.Type.(*types.Basic); !ok || (et.Info()&types.IsUntyped) == 0 {
+		return
+	}
+	if x := constant.ToInt(e.CVal); x.Kind() == constant.Int && !intFits(t.Kind(), x) {
+		pos := ctx.Position(val.Pos())
+		ctx.handleCodeErrorf(&pos, "constant %v overflows %v", x, typ)
+	}
+}
+
+// checkTypedOverflow reports e, the value of expr, if it is a typed integer
+// constant that doesn't fit in its own type.
+func checkTypedOverflow(ctx *blockCtx, e *gox.Element, expr ast.Expr) {
+	if e.CVal == nil {
+		return
+	}
+	t, ok := e.Type.Underlying().(*types.Basic)
+	if !ok || (t.Info()&types.IsInteger) == 0 || (t.Info()&types.IsUntyped) != 0 {
+		return
+	}
+	if x := constant.ToInt(e.CVal); x.Kind() == constant.Int && !intFits(t.Kind(), x) {
+		pos := ctx.Position(expr.Pos())
+		ctx.handleCodeErrorf(&pos, "constant %v overflows %v", x, e.Type)
+	}
+}
+
+// checkUntypedNil reports untyped nil values that a variable would take its
+// type from. The values of vals must be on the top of the code stack.
+func checkUntypedNil(ctx *blockCtx, vals []ast.Expr, context string) {
+	n := len(vals)
+	for i, val := range vals {
+		if t, ok := ctx.cb.Get(i - n).Type.(*types.Basic); ok && t.Kind() == types.UntypedNil {
+			pos := ctx.Position(val.Pos())
+			ctx.handleCodeErrorf(&pos, "use of untyped nil in %s", context)
+		}
+	}
+}
+
+func intFits(kind types.BasicKind, x constant.Value) bool {
+	if v, ok := constant.Int64Val(x); ok {
+		switch kind {
+		case types.Int8:
+			return v >= math.MinInt8 && v <= math.MaxInt8
+		case types.Int16:
+			return v >= math.MinInt16 && v <= math.MaxInt16
+		case types.Int32:
+			return v >= math.MinInt32 && v <= math.MaxInt32
+		case types.Uint8:
+			return v >= 0 && v <= math.MaxUint8
+		case types.Uint16:
+			return v >= 0 && v <= math.MaxUint16
+		case types.Uint32:
+			return v >= 0 && v <= math.MaxUint32
+		case types.Uint, types.Uint64, types.Uintptr:
+			return v >= 0
+		}
+		return true
+	}
+	switch kind {
+	case types.Uint, types.Uint64, types.Uintptr:
+		_, ok := constant.Uint64Val(x)
+		return ok
+	}
+	return false
+}
+
 func toInterfaceType(ctx *blockCtx, v *ast.InterfaceType) types.Type {
 	methodsList := v.Methods.List
 	if methodsList == nil {
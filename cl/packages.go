@@ -18,6 +18,7 @@ package cl
 
 import (
 	"fmt"
+	"go/types"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -83,6 +84,42 @@ func modPaths(conf *Config) (root string, modPath string) {
 
 // -----------------------------------------------------------------------------
 
+// loadCompiledPkgs returns a loader that takes the packages in compiled and
+// the packages they import from there, and loads other packages by load. So a
+// type of an imported package is the same in the compiled packages and in the
+// package being compiled, even if they are compiled with different loaders.
+func loadCompiledPkgs(compiled map[string]*gox.Package, load gox.LoadPkgsFunc) gox.LoadPkgsFunc {
+	pkgs := make(map[string]*types.Package)
+	var addPkg func(pkg *types.Package)
+	addPkg = func(pkg *types.Package) {
+		if _, ok := pkgs[pkg.Path()]; !ok {
+			pkgs[pkg.Path()] = pkg
+			for _, imp := range pkg.Imports() {
+				addPkg(imp)
+			}
+		}
+	}
+	for _, pkg := range compiled {
+		addPkg(pkg.Types)
+	}
+	return func(at *gox.Package, importPkgs map[string]*gox.PkgRef, pkgPaths ...string) int {
+		var others []string
+		for _, pkgPath := range pkgPaths {
+			if pkg, ok := pkgs[pkgPath]; ok {
+				if ref, ok := importPkgs[pkgPath]; ok {
+					ref.ID, ref.Types = pkgPath, pkg
+				}
+			} else {
+				others = append(others, pkgPath)
+			}
+		}
+		if others == nil {
+			return 0
+		}
+		return load(at, importPkgs, others...)
+	}
+}
+
 type PkgsLoader struct {
 	cached     *gox.LoadPkgsCached
 	genGoPkg   func(pkgDir string, base *Config) error
@@ -56,6 +56,13 @@ func commentStmt(ctx *blockCtx, stmt ast.Stmt) {
 	}
 }
 
+// stmtBlock is a statement list being compiled, with the index of the
+// statement currently compiled.
+type stmtBlock struct {
+	list []ast.Stmt
+	idx  int
+}
+
 func compileStmts(ctx *blockCtx, body []ast.Stmt) {
 	for _, stmt := range body {
 		if v, ok := stmt.(*ast.LabeledStmt); ok {
@@ -63,9 +70,13 @@ func compileStmts(ctx *blockCtx, body []ast.Stmt) {
 			ctx.cb.NewLabel(l.Pos(), l.Name)
 		}
 	}
-	for _, stmt := range body {
+	blk := &stmtBlock{list: body}
+	ctx.blocks = append(ctx.blocks, blk)
+	for i, stmt := range body {
+		blk.idx = i
 		compileStmt(ctx, stmt)
 	}
+	ctx.blocks = ctx.blocks[:len(ctx.blocks)-1]
 }
 
 func compileStmt(ctx *blockCtx, stmt ast.Stmt) {
@@ -166,17 +177,83 @@ func compileReturnStmt(ctx *blockCtx, expr *ast.ReturnStmt) {
 			compileExpr(ctx, ret, twoValue)
 		}
 	}
+	if len(expr.Results) == 0 {
+		checkNamedResults(ctx, expr)
+	} else {
+		checkResultsOverflow(ctx, expr)
+	}
 	ctx.cb.Return(len(expr.Results), expr)
 }
 
+// checkResultsOverflow reports untyped constants returned as integer results
+// that they don't fit in. The results must be on the top of the code stack.
+func checkResultsOverflow(ctx *blockCtx, expr *ast.ReturnStmt) {
+	results := ctx.cb.Func().Type().(*types.Signature).Results()
+	n := len(expr.Results)
+	if results.Len() != n {
+		return
+	}
+	for i, ret := range expr.Results {
+		checkConstOverflow(ctx, results.At(i).Type(), ctx.cb.Get(i-n), ret)
+	}
+}
+
+// checkNamedResults reports named results that are shadowed at a bare return.
+func checkNamedResults(ctx *blockCtx, expr *ast.ReturnStmt) {
+	results := ctx.cb.Func().Type().(*types.Signature).Results()
+	scope := ctx.cb.Scope()
+	for i, n := 0, results.Len(); i < n; i++ {
+		ret := results.At(i)
+		name := ret.Name()
+		if name == "" || name == "_" {
+			continue
+		}
+		if _, o := scope.LookupParent(name, token.NoPos); o != ret {
+			pos := ctx.Position(expr.Pos())
+			ctx.handleCodeErrorf(&pos, "result parameter %s not in scope at return", name)
+		}
+	}
+}
+
 func compileIncDecStmt(ctx *blockCtx, expr *ast.IncDecStmt) {
-	compileExprLHS(ctx, expr.X)
+	typ := compileExprLHS(ctx, expr.X)
+	if typ != nil && !isNumeric(typ) {
+		if o, _, _ := types.LookupFieldOrMethod(typ, true, ctx.pkg.Types, unaryGopNames[expr.Tok.String()]); o == nil {
+			src, _ := ctx.LoadExpr(expr.X)
+			panic(ctx.newCodeErrorf(expr.Pos(), "invalid operation: %s%v (non-numeric type %v)", src, expr.Tok, typ))
+		}
+	}
 	ctx.cb.IncDec(gotoken.Token(expr.Tok))
 }
 
+func isBasic(typ types.Type) bool {
+	_, ok := typ.Underlying().(*types.Basic)
+	return ok
+}
+
+func isNumeric(typ types.Type) bool {
+	t, ok := typ.Underlying().(*types.Basic)
+	return ok && (t.Info()&types.IsNumeric) != 0
+}
+
 func compileSendStmt(ctx *blockCtx, expr *ast.SendStmt) {
 	compileExpr(ctx, expr.Chan)
 	compileExpr(ctx, expr.Value)
+	ch, val := ctx.cb.Get(-2), ctx.cb.Get(-1)
+	t, ok := ch.Type.Underlying().(*types.Chan)
+	if !ok {
+		src, _ := ctx.LoadExpr(expr)
+		panic(ctx.newCodeErrorf(expr.Pos(), "invalid operation: %s (send to non-chan type %v)", src, ch.Type))
+	}
+	if t.Dir() == types.RecvOnly {
+		src, _ := ctx.LoadExpr(expr)
+		panic(ctx.newCodeErrorf(expr.Pos(), "invalid operation: %s (send to receive-only type %v)", src, ch.Type))
+	}
+	if !gox.AssignableConv(ctx.pkg, val.Type, t.Elem(), val) {
+		src, _ := ctx.LoadExpr(expr.Value)
+		panic(ctx.newCodeErrorf(expr.Value.Pos(), "cannot use %s (type %v) as type %v in send", src, val.Type, t.Elem()))
+	}
+	checkConstOverflow(ctx, t.Elem(), val, expr.Value)
 	ctx.cb.Send()
 }
 
@@ -204,25 +281,69 @@ func compileAssignStmt(ctx *blockCtx, expr *ast.AssignStmt) {
 		for _, rhs := range expr.Rhs {
 			compileExpr(ctx, rhs, twoValue)
 		}
+		checkUntypedNil(ctx, expr.Rhs, "assignment")
 		ctx.cb.EndInit(len(expr.Rhs))
 		return
 	}
-	for _, lhs := range expr.Lhs {
-		compileExprLHS(ctx, lhs)
+	lhsTypes := make([]types.Type, len(expr.Lhs))
+	for i, lhs := range expr.Lhs {
+		lhsTypes[i] = compileExprLHS(ctx, lhs)
 	}
 	for _, rhs := range expr.Rhs {
 		compileExpr(ctx, rhs, twoValue)
 	}
 	if tok == token.ASSIGN {
+		checkAssignOverflow(ctx, expr, lhsTypes)
 		ctx.cb.AssignWith(len(expr.Lhs), len(expr.Rhs), expr)
 		return
 	}
 	if len(expr.Lhs) != 1 || len(expr.Rhs) != 1 {
-		panic("TODO: invalid syntax of assign by operator")
+		panic(ctx.newCodeErrorf(
+			expr.Pos(), "assignment operation %v requires single-valued expressions", tok))
 	}
+	checkAssignOp(ctx, expr, lhsTypes[0])
 	ctx.cb.AssignOp(gotoken.Token(tok), expr)
 }
 
+// checkAssignOverflow reports untyped constants assigned to integer variables
+// that they don't fit in, where lhsTypes are the types of the variables. The
+// references and the values must be on the top of the code stack.
+func checkAssignOverflow(ctx *blockCtx, expr *ast.AssignStmt, lhsTypes []types.Type) {
+	n := len(expr.Rhs)
+	if len(expr.Lhs) != n {
+		return
+	}
+	for i, rhs := range expr.Rhs {
+		if typ := lhsTypes[i]; typ != nil {
+			checkConstOverflow(ctx, typ, ctx.cb.Get(i-n), rhs)
+		}
+	}
+}
+
+// checkAssignOp checks x op= y as the binary expression x op y, where x is of
+// type typ. The reference to x and the value of y must be on the top of the
+// code stack.
+func checkAssignOp(ctx *blockCtx, expr *ast.AssignStmt, typ types.Type) {
+	if typ == nil {
+		return
+	}
+	stk := ctx.cb.InternalStack()
+	lhs, rhs := expr.Lhs[0], expr.Rhs[0]
+	x := &gox.Element{Val: stk.Get(-2).Val, Type: typ, Src: lhs}
+	y := stk.Pop()
+	stk.Push(x)
+	stk.Push(y)
+	v := &ast.BinaryExpr{X: lhs, OpPos: expr.TokPos, Op: expr.Tok - token.ADD_ASSIGN + token.ADD, Y: rhs}
+	checkBinaryOp(ctx, v)
+	if v.Op != token.SHL && v.Op != token.SHR && isBasic(x.Type) && isBasic(y.Type) &&
+		!gox.AssignableConv(ctx.pkg, y.Type, x.Type, y) {
+		src, _ := ctx.LoadExpr(v)
+		panic(ctx.newCodeErrorf(v.Pos(), "invalid operation: %s (mismatched types %v and %v)", src, x.Type, y.Type))
+	}
+	stk.PopN(2)
+	stk.Push(y)
+}
+
 // forRange(names...) x rangeAssignThen
 //    body
 // end
@@ -232,6 +353,7 @@ func compileAssignStmt(ctx *blockCtx, expr *ast.AssignStmt) {
 func compileRangeStmt(ctx *blockCtx, v *ast.RangeStmt) {
 	cb := ctx.cb
 	comments := cb.Comments()
+	x := compileRangeExpr(ctx, v.X, v.Value != nil)
 	if v.Tok == token.DEFINE {
 		names := make([]string, 1, 2)
 		if v.Key == nil {
@@ -243,7 +365,7 @@ func compileRangeStmt(ctx *blockCtx, v *ast.RangeStmt) {
 			names = append(names, v.Value.(*ast.Ident).Name)
 		}
 		cb.ForRange(names...)
-		compileExpr(ctx, v.X)
+		cb.InternalStack().Push(x)
 	} else {
 		cb.ForRange()
 		n := 0
@@ -260,13 +382,18 @@ func compileRangeStmt(ctx *blockCtx, v *ast.RangeStmt) {
 			compileExprLHS(ctx, v.Value)
 			n++
 		}
-		compileExpr(ctx, v.X)
+		cb.InternalStack().Push(x)
 	}
 	pos := v.TokPos
 	if pos == 0 {
 		pos = v.For
 	}
 	cb.RangeAssignThen(pos)
+	if v.Tok == token.DEFINE {
+		key, _ := v.Key.(*ast.Ident)
+		value, _ := v.Value.(*ast.Ident)
+		recordVarIdents(ctx, key, value)
+	}
 	compileStmts(ctx, v.Body.List)
 	cb.SetComments(comments, true)
 	setBodyHandler(ctx)
@@ -276,6 +403,7 @@ func compileRangeStmt(ctx *blockCtx, v *ast.RangeStmt) {
 func compileForPhraseStmt(ctx *blockCtx, v *ast.ForPhraseStmt) {
 	cb := ctx.cb
 	comments := cb.Comments()
+	x := compileRangeExpr(ctx, v.X, v.Key != nil && v.Value != nil)
 	names := make([]string, 1, 2)
 	if v.Key == nil {
 		names[0] = "_"
@@ -283,11 +411,15 @@ func compileForPhraseStmt(ctx *blockCtx, v *ast.ForPhraseStmt) {
 		names[0] = v.Key.Name
 	}
 	if v.Value != nil {
+		if _, ok := x.Type.Underlying().(*types.Chan); ok && v.Key == nil {
+			names = names[:0] // for v <- ch
+		}
 		names = append(names, v.Value.Name)
 	}
 	cb.ForRange(names...)
-	compileExpr(ctx, v.X)
+	cb.InternalStack().Push(x)
 	cb.RangeAssignThen(v.TokPos)
+	recordVarIdents(ctx, v.Key, v.Value)
 	if v.Cond != nil {
 		cb.If()
 		compileExpr(ctx, v.Cond)
@@ -303,6 +435,44 @@ func compileForPhraseStmt(ctx *blockCtx, v *ast.ForPhraseStmt) {
 	cb.End()
 }
 
+// recordVarIdents records the identifiers of the variables just declared by a
+// for range or a type switch statement, which are declared without positions.
+func recordVarIdents(ctx *blockCtx, idents ...*ast.Ident) {
+	if ctx.varIdents == nil {
+		return
+	}
+	scope := ctx.cb.Scope()
+	for _, ident := range idents {
+		if ident != nil && ident.Name != "_" {
+			if o := scope.Lookup(ident.Name); o != nil {
+				ctx.varIdents[o] = ident
+			}
+		}
+	}
+}
+
+// compileRangeExpr compiles the expression of a for range statement before
+// the statement starts, so that errors in it don't leave the statement open.
+// It also reports ranging over a send-only channel, or over a channel with
+// two iteration variables.
+func compileRangeExpr(ctx *blockCtx, x ast.Expr, twoValue bool) *gox.Element {
+	compileExpr(ctx, x)
+	e := ctx.cb.InternalStack().Pop()
+	t, ok := e.Type.Underlying().(*types.Chan)
+	if !ok {
+		return e
+	}
+	src, _ := ctx.LoadExpr(x)
+	typ := e.Type
+	if t.Dir() == types.SendOnly {
+		panic(ctx.newCodeErrorf(x.Pos(), "invalid operation: range %s (receive from send-only type %v)", src, typ))
+	}
+	if twoValue {
+		panic(ctx.newCodeErrorf(x.Pos(), "range over %s permits only one iteration variable", src))
+	}
+	return e
+}
+
 // for init; cond then
 //    body
 //    post
@@ -367,13 +537,15 @@ func compileTypeSwitchStmt(ctx *blockCtx, v *ast.TypeSwitchStmt) {
 	var cb = ctx.cb
 	comments := cb.Comments()
 	var name string
+	var ident *ast.Ident
 	var ta *ast.TypeAssertExpr
 	switch stmt := v.Assign.(type) {
 	case *ast.AssignStmt:
 		if stmt.Tok != token.DEFINE || len(stmt.Lhs) != 1 || len(stmt.Rhs) != 1 {
 			panic("TODO: type switch syntax error")
 		}
-		name = stmt.Lhs[0].(*ast.Ident).Name
+		ident = stmt.Lhs[0].(*ast.Ident)
+		name = ident.Name
 		ta = stmt.Rhs[0].(*ast.TypeAssertExpr)
 	case *ast.ExprStmt:
 		ta = stmt.X.(*ast.TypeAssertExpr)
@@ -386,7 +558,13 @@ func compileTypeSwitchStmt(ctx *blockCtx, v *ast.TypeSwitchStmt) {
 		compileStmt(ctx, v.Init)
 	}
 	compileExpr(ctx, ta.X)
+	if typ := cb.Get(-1).Type; !isInterface(typ) {
+		src, _ := ctx.LoadExpr(ta.X)
+		panic(ctx.newCodeErrorf(ta.X.Pos(), "cannot type switch on non-interface value %s (type %v)", src, typ))
+	}
 	cb.TypeAssertThen()
+	var seen []ast.Expr
+	var seenTypes []types.Type
 	for _, stmt := range v.Body.List {
 		c, ok := stmt.(*ast.CaseClause)
 		if !ok {
@@ -394,8 +572,23 @@ func compileTypeSwitchStmt(ctx *blockCtx, v *ast.TypeSwitchStmt) {
 		}
 		for _, citem := range c.List {
 			compileExpr(ctx, citem)
+			typ := cb.Get(-1).Type
+			if tt, ok := typ.(*gox.TypeType); ok {
+				typ = tt.Type()
+			}
+			for i, t := range seenTypes {
+				if types.Identical(t, typ) {
+					src, _ := ctx.LoadExpr(citem)
+					pos := ctx.Position(citem.Pos())
+					ctx.handleCodeErrorf(&pos, "duplicate case %s in type switch\n\tprevious case at %v",
+						src, ctx.Position(seen[i].Pos()))
+					break
+				}
+			}
+			seen, seenTypes = append(seen, citem), append(seenTypes, typ)
 		}
 		cb.TypeCase(len(c.List)) // TypeCase(0) means default case
+		recordVarIdents(ctx, ident)
 		compileStmts(ctx, c.Body)
 		commentStmt(ctx, stmt)
 		cb.End()
@@ -404,6 +597,11 @@ func compileTypeSwitchStmt(ctx *blockCtx, v *ast.TypeSwitchStmt) {
 	cb.End()
 }
 
+func isInterface(typ types.Type) bool {
+	_, ok := typ.Underlying().(*types.Interface)
+	return ok
+}
+
 // switch init; tag then
 // expr1 expr2 ... exprN case(N)
 //    ...
@@ -425,7 +623,7 @@ func compileSwitchStmt(ctx *blockCtx, v *ast.SwitchStmt) {
 		cb.None() // switch {...}
 	}
 	cb.Then()
-	for _, stmt := range v.Body.List {
+	for i, stmt := range v.Body.List {
 		c, ok := stmt.(*ast.CaseClause)
 		if !ok {
 			log.Panicln("TODO: compile SwitchStmt failed - case clause expected.")
@@ -437,7 +635,12 @@ func compileSwitchStmt(ctx *blockCtx, v *ast.SwitchStmt) {
 		body, has := hasFallthrough(c.Body)
 		compileStmts(ctx, body)
 		if has {
-			cb.Fallthrough()
+			if i == len(v.Body.List)-1 {
+				pos := ctx.Position(c.Body[len(body)].Pos())
+				ctx.handleCodeErrorf(&pos, "cannot fallthrough final case in switch")
+			} else {
+				cb.Fallthrough()
+			}
 		}
 		commentStmt(ctx, stmt)
 		cb.End()
@@ -478,6 +681,10 @@ func compileSelectStmt(ctx *blockCtx, v *ast.SelectStmt) {
 		}
 		var n int
 		if c.Comm != nil {
+			if !isCommStmt(c.Comm) {
+				pos := ctx.Position(c.Comm.Pos())
+				ctx.handleCodeErrorf(&pos, "select case must be receive, send or assign recv")
+			}
 			compileStmt(ctx, c.Comm)
 			n = 1
 		}
@@ -490,12 +697,42 @@ func compileSelectStmt(ctx *blockCtx, v *ast.SelectStmt) {
 	cb.End()
 }
 
+func isCommStmt(stmt ast.Stmt) bool {
+	switch v := stmt.(type) {
+	case *ast.SendStmt:
+		return true
+	case *ast.ExprStmt:
+		return isRecvExpr(v.X)
+	case *ast.AssignStmt:
+		return (v.Tok == token.ASSIGN || v.Tok == token.DEFINE) &&
+			len(v.Lhs) <= 2 && len(v.Rhs) == 1 && isRecvExpr(v.Rhs[0])
+	}
+	return false
+}
+
+func isRecvExpr(x ast.Expr) bool {
+	for {
+		switch v := x.(type) {
+		case *ast.ParenExpr:
+			x = v.X
+		case *ast.UnaryExpr:
+			return v.Op == token.ARROW
+		default:
+			return false
+		}
+	}
+}
+
 func compileBranchStmt(ctx *blockCtx, v *ast.BranchStmt) {
 	label := v.Label
 	switch v.Tok {
 	case token.GOTO:
+		if label == nil {
+			panic(ctx.newCodeError(v.Pos(), "missing label in goto statement"))
+		}
 		cb := ctx.cb
 		if l, ok := cb.LookupLabel(label.Name); ok {
+			checkGotoJump(ctx, v)
 			cb.Goto(l)
 			return
 		}
@@ -504,9 +741,9 @@ func compileBranchStmt(ctx *blockCtx, v *ast.BranchStmt) {
 			Args: []ast.Expr{label},
 		}, clIdentGoto)
 	case token.BREAK:
-		ctx.cb.Break(getLabel(ctx, label))
+		ctx.cb.Break(getBranchLabel(ctx, v))
 	case token.CONTINUE:
-		ctx.cb.Continue(getLabel(ctx, label))
+		ctx.cb.Continue(getBranchLabel(ctx, v))
 	case token.FALLTHROUGH:
 		pos := ctx.Position(v.Pos())
 		ctx.handleCodeErrorf(&pos, "fallthrough statement out of place")
@@ -515,6 +752,66 @@ func compileBranchStmt(ctx *blockCtx, v *ast.BranchStmt) {
 	}
 }
 
+// checkGotoJump reports a forward goto that jumps over a variable declaration
+// into the scope of the variable.
+func checkGotoJump(ctx *blockCtx, v *ast.BranchStmt) {
+	for i := len(ctx.blocks) - 1; i >= 0; i-- {
+		blk := ctx.blocks[i]
+		for j := blk.idx + 1; j < len(blk.list); j++ {
+			if l, ok := blk.list[j].(*ast.LabeledStmt); ok && l.Label.Name == v.Label.Name {
+				for _, stmt := range blk.list[blk.idx+1 : j] {
+					if isVarDecl(stmt) {
+						pos := ctx.Position(v.Pos())
+						ctx.handleCodeErrorf(&pos, "goto %s jumps over variable declaration at line %d",
+							v.Label.Name, ctx.Position(stmt.Pos()).Line)
+						return
+					}
+				}
+				return
+			}
+		}
+	}
+}
+
+func isVarDecl(stmt ast.Stmt) bool {
+	switch v := stmt.(type) {
+	case *ast.LabeledStmt:
+		return isVarDecl(v.Stmt)
+	case *ast.AssignStmt:
+		return v.Tok == token.DEFINE
+	case *ast.DeclStmt:
+		d, ok := v.Decl.(*ast.GenDecl)
+		return ok && d.Tok == token.VAR
+	}
+	return false
+}
+
+// getBranchLabel returns the label of a break or continue statement. The label
+// must name an enclosing statement that the branch can apply to.
+func getBranchLabel(ctx *blockCtx, v *ast.BranchStmt) *gox.Label {
+	l := getLabel(ctx, v.Label)
+	if l != nil && !isBranchTarget(ctx, v.Label.Name, v.Tok) {
+		pos := ctx.Position(v.Label.Pos())
+		ctx.handleCodeErrorf(&pos, "invalid %v label %s", v.Tok, v.Label.Name)
+	}
+	return l
+}
+
+func isBranchTarget(ctx *blockCtx, name string, tok token.Token) bool {
+	for i := len(ctx.labels) - 1; i >= 0; i-- {
+		if v := ctx.labels[i]; v.Label.Name == name {
+			switch v.Stmt.(type) {
+			case *ast.ForStmt, *ast.RangeStmt, *ast.ForPhraseStmt:
+				return true
+			case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+				return tok == token.BREAK
+			}
+			return false
+		}
+	}
+	return false
+}
+
 func getLabel(ctx *blockCtx, label *ast.Ident) *gox.Label {
 	if label != nil {
 		if l, ok := ctx.cb.LookupLabel(label.Name); ok {
@@ -529,7 +826,9 @@ func getLabel(ctx *blockCtx, label *ast.Ident) *gox.Label {
 func compileLabeledStmt(ctx *blockCtx, v *ast.LabeledStmt) {
 	l, _ := ctx.cb.LookupLabel(v.Label.Name)
 	ctx.cb.Label(l)
+	ctx.labels = append(ctx.labels, v)
 	compileStmt(ctx, v.Stmt)
+	ctx.labels = ctx.labels[:len(ctx.labels)-1]
 }
 
 func compileGoStmt(ctx *blockCtx, v *ast.GoStmt) {